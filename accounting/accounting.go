@@ -0,0 +1,346 @@
+// Package accounting computes realized and unrealized profit and
+// loss from private trade history, under FIFO, LIFO or average-cost
+// lot matching.
+//
+// Every pair is accounted in its second (quote) currency: buying
+// opens lots of the base currency with a cost basis in the quote
+// currency, selling closes them. The exchange fee (PairInfo.Fee, in
+// percent) is taken from the received currency, as the exchange
+// does: a buy of Amount at Rate costs Amount*Rate and yields
+// Amount*(1-Fee/100) of the base currency; a sell of Amount yields
+// Amount*Rate*(1-Fee/100).
+//
+// Selling more than the lots hold (e.g. coins bought before the
+// history starts) has no known cost basis; such amounts are counted
+// as Unmatched and left out of realized PnL.
+package accounting
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Method selects how sells are matched against earlier buys.
+type Method int
+
+const (
+	FIFO        Method = iota // oldest lots are sold first
+	LIFO                      // newest lots are sold first
+	AverageCost               // all lots are merged at average cost
+)
+
+func (m Method) String() string {
+	switch m {
+	case FIFO:
+		return "fifo"
+	case LIFO:
+		return "lifo"
+	case AverageCost:
+		return "average"
+	}
+	return "unknown"
+}
+
+// ParseMethod converts "fifo", "lifo" or "average" into a Method.
+func ParseMethod(name string) (Method, error) {
+	switch strings.ToLower(name) {
+	case "fifo":
+		return FIFO, nil
+	case "lifo":
+		return LIFO, nil
+	case "average", "avg":
+		return AverageCost, nil
+	}
+	return FIFO, errors.New("Unknown accounting method: " + name)
+}
+
+// Realization records a sell matched against held lots.
+type Realization struct {
+	Pair     string
+	Time     time.Time
+	Amount   float64 // base currency sold from lots
+	Proceeds float64 // quote currency received, net of fee
+	Cost     float64 // cost basis of the amount sold
+}
+
+// PnL is the realized profit (or loss, when negative).
+func (r Realization) PnL() float64 { return r.Proceeds - r.Cost }
+
+type lot struct {
+	Amount float64
+	Cost   float64
+}
+
+// Position is the state of a pair after processing trades.
+type Position struct {
+	Pair      string
+	Amount    float64 // base currency held in lots
+	Cost      float64 // cost basis of Amount, in quote currency
+	Realized  float64 // realized PnL, in quote currency
+	Fees      float64 // fees paid, in quote currency equivalent
+	Unmatched float64 // base currency sold without a cost basis
+}
+
+// AverageCost returns the cost basis per unit of the held amount.
+func (p Position) AverageCost() float64 {
+	if p.Amount == 0 {
+		return 0
+	}
+	return p.Cost / p.Amount
+}
+
+type position struct {
+	Position
+	lots []lot
+}
+
+// Book accumulates trades and keeps positions per pair.
+type Book struct {
+	Method       Method
+	Pairs        map[string]btce.PairInfo // fee source
+	Realizations []Realization
+	positions    map[string]*position
+}
+
+// NewBook creates a Book using fees from pairs (normally
+// PublicInfo.Pairs).
+func NewBook(method Method, pairs map[string]btce.PairInfo) *Book {
+	return &Book{Method: method, Pairs: pairs,
+		positions: map[string]*position{}}
+}
+
+func (b *Book) position(pair string) *position {
+	p, ok := b.positions[pair]
+	if !ok {
+		p = &position{Position: Position{Pair: pair}}
+		b.positions[pair] = p
+	}
+	return p
+}
+
+// Add processes a single trade. Trades must be added in chronological
+// order; use AddHistory for a whole TradeHistoryResult.
+func (b *Book) Add(trade btce.TradeHistoryItem) {
+	qfee := (100 - b.Pairs[trade.Pair].Fee) / 100
+	p := b.position(trade.Pair)
+	switch trade.Type {
+	case "buy":
+		bought := lot{Amount: trade.Amount * qfee,
+			Cost: trade.Amount * trade.Rate}
+		p.Fees += trade.Amount * (1 - qfee) * trade.Rate
+		if b.Method == AverageCost && len(p.lots) > 0 {
+			p.lots[0].Amount += bought.Amount
+			p.lots[0].Cost += bought.Cost
+		} else {
+			p.lots = append(p.lots, bought)
+		}
+		p.Amount += bought.Amount
+		p.Cost += bought.Cost
+	case "sell":
+		proceeds := trade.Amount * trade.Rate * qfee
+		p.Fees += trade.Amount * trade.Rate * (1 - qfee)
+		sold, cost := p.consume(trade.Amount, b.Method)
+		if sold < trade.Amount {
+			p.Unmatched += trade.Amount - sold
+		}
+		if sold > 0 {
+			r := Realization{Pair: trade.Pair,
+				Time:     time.Unix(trade.Timestamp, 0),
+				Amount:   sold,
+				Proceeds: proceeds * sold / trade.Amount,
+				Cost:     cost}
+			p.Realized += r.PnL()
+			b.Realizations = append(b.Realizations, r)
+		}
+	}
+}
+
+// consume removes up to amount from the lots, returning the amount
+// actually removed and its cost basis.
+func (p *position) consume(amount float64, method Method) (float64, float64) {
+	sold, cost := 0.0, 0.0
+	for amount > 0 && len(p.lots) > 0 {
+		i := 0
+		if method == LIFO {
+			i = len(p.lots) - 1
+		}
+		l := &p.lots[i]
+		if amount < l.Amount {
+			part := l.Cost * amount / l.Amount
+			sold, cost = sold+amount, cost+part
+			l.Amount -= amount
+			l.Cost -= part
+			break
+		}
+		sold, cost, amount = sold+l.Amount, cost+l.Cost, amount-l.Amount
+		p.lots = append(p.lots[:i], p.lots[i+1:]...)
+	}
+	p.Amount -= sold
+	p.Cost -= cost
+	return sold, cost
+}
+
+// AddHistory processes a TradeHistoryResult in chronological order
+// (by timestamp, then by trade id).
+func (b *Book) AddHistory(history btce.TradeHistoryResult) {
+	ids := make([]uint64, 0, len(history))
+	for id := range history {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		ti, tj := history[ids[i]].Timestamp, history[ids[j]].Timestamp
+		if ti != tj {
+			return ti < tj
+		}
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		b.Add(history[id])
+	}
+}
+
+// Positions returns current positions for every traded pair.
+func (b *Book) Positions() map[string]Position {
+	result := make(map[string]Position, len(b.positions))
+	for pair, p := range b.positions {
+		result[pair] = p.Position
+	}
+	return result
+}
+
+// PairReport is a position marked to market.
+type PairReport struct {
+	Position
+	Currency   string  // quote currency of all money amounts
+	Mark       float64 // rate used for valuation
+	Unrealized float64 // Amount*Mark - Cost
+}
+
+// quoteCurrency returns the second currency of a pair name.
+func quoteCurrency(pair string) string {
+	return pair[strings.LastIndex(pair, "_")+1:]
+}
+
+// markRate chooses a valuation rate from a ticker: the last trade
+// rate if available, the average otherwise.
+func markRate(t btce.TickerInfo) float64 {
+	if t.Last != 0 {
+		return t.Last
+	}
+	return t.Average
+}
+
+// Mark values positions at ticker rates. Pairs missing from tickers
+// are reported with zero Mark and Unrealized.
+func (b *Book) Mark(tickers map[string]btce.TickerInfo) []PairReport {
+	reports := []PairReport{}
+	for pair, p := range b.positions {
+		r := PairReport{Position: p.Position, Currency: quoteCurrency(pair)}
+		if t, ok := tickers[pair]; ok {
+			r.Mark = markRate(t)
+			r.Unrealized = r.Amount*r.Mark - r.Cost
+		}
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Pair < reports[j].Pair })
+	return reports
+}
+
+// MarkClient values positions at current rates, calling GetTicker
+// for all traded pairs.
+func (b *Book) MarkClient(c *btce.Client) ([]PairReport, error) {
+	pairs := []string{}
+	for pair := range b.positions {
+		pairs = append(pairs, pair)
+	}
+	if len(pairs) == 0 {
+		return []PairReport{}, nil
+	}
+	sort.Strings(pairs)
+	tickers, err := c.GetTicker(pairs)
+	if err != nil {
+		return nil, err
+	}
+	return b.Mark(tickers), nil
+}
+
+// CurrencyTotal sums pair reports sharing a quote currency.
+type CurrencyTotal struct {
+	Realized   float64
+	Unrealized float64
+	Fees       float64
+}
+
+// Totals sums realized and unrealized PnL per currency.
+func Totals(reports []PairReport) map[string]CurrencyTotal {
+	totals := map[string]CurrencyTotal{}
+	for _, r := range reports {
+		t := totals[r.Currency]
+		t.Realized += r.Realized
+		t.Unrealized += r.Unrealized
+		t.Fees += r.Fees
+		totals[r.Currency] = t
+	}
+	return totals
+}
+
+// PeriodReport sums realizations of a pair over a period.
+type PeriodReport struct {
+	Start    time.Time
+	Pair     string
+	Currency string
+	Sells    int
+	Amount   float64
+	Proceeds float64
+	Cost     float64
+	Realized float64
+}
+
+// Daily truncates a time to the start of its UTC day.
+func Daily(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Monthly truncates a time to the start of its UTC month.
+func Monthly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Periods groups realizations by pair and by period, given a function
+// mapping a time to the start of its period (like Daily or Monthly).
+// Reports are ordered by period, then by pair.
+func (b *Book) Periods(period func(time.Time) time.Time) []PeriodReport {
+	type key struct {
+		start time.Time
+		pair  string
+	}
+	index := map[key]int{}
+	reports := []PeriodReport{}
+	for _, r := range b.Realizations {
+		k := key{period(r.Time.UTC()), r.Pair}
+		i, ok := index[k]
+		if !ok {
+			i = len(reports)
+			index[k] = i
+			reports = append(reports, PeriodReport{Start: k.start,
+				Pair: r.Pair, Currency: quoteCurrency(r.Pair)})
+		}
+		p := &reports[i]
+		p.Sells++
+		p.Amount += r.Amount
+		p.Proceeds += r.Proceeds
+		p.Cost += r.Cost
+		p.Realized += r.PnL()
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].Start.Equal(reports[j].Start) {
+			return reports[i].Start.Before(reports[j].Start)
+		}
+		return reports[i].Pair < reports[j].Pair
+	})
+	return reports
+}
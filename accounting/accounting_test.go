@@ -0,0 +1,62 @@
+package accounting
+
+import (
+	"math"
+	"testing"
+
+	"github.com/akovalenko/go-btce"
+)
+
+var history = btce.TradeHistoryResult{
+	1: {Pair: "btc_usd", Type: "buy", Amount: 1, Rate: 100, Timestamp: 1},
+	2: {Pair: "btc_usd", Type: "buy", Amount: 1, Rate: 200, Timestamp: 2},
+	3: {Pair: "btc_usd", Type: "sell", Amount: 1, Rate: 300, Timestamp: 3},
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestMethods(t *testing.T) {
+	pairs := map[string]btce.PairInfo{"btc_usd": {}}
+	for method, realized := range map[Method]float64{
+		FIFO: 200, LIFO: 100, AverageCost: 150} {
+		b := NewBook(method, pairs)
+		b.AddHistory(history)
+		p := b.Positions()["btc_usd"]
+		if !near(p.Realized, realized) || !near(p.Amount, 1) {
+			t.Error(method, "realized", p.Realized, "holding", p.Amount)
+		}
+		r := b.Mark(map[string]btce.TickerInfo{"btc_usd": {Last: 400}})
+		if !near(r[0].Unrealized+r[0].Realized, 400) {
+			t.Error(method, "total PnL", r[0].Unrealized+r[0].Realized)
+		}
+	}
+}
+
+func TestFee(t *testing.T) {
+	b := NewBook(FIFO, map[string]btce.PairInfo{"btc_usd": {Fee: 0.2}})
+	b.AddHistory(history)
+	p := b.Positions()["btc_usd"]
+	// 1.996 btc bought for 300 usd (lots of 0.998 btc at 100 and 200
+	// usd each); 1 btc sold for 299.4 usd. FIFO takes the first lot
+	// (100 usd) and 0.002 btc of the second (0.002*200/0.998 usd).
+	if !near(p.Amount, 0.996) || !near(p.Realized, 299.4-100-0.002*200/0.998) {
+		t.Error("holding", p.Amount, "realized", p.Realized)
+	}
+	if p.Unmatched != 0 {
+		t.Error("unmatched", p.Unmatched)
+	}
+}
+
+func TestPeriods(t *testing.T) {
+	b := NewBook(FIFO, map[string]btce.PairInfo{})
+	b.AddHistory(history)
+	b.Add(btce.TradeHistoryItem{Pair: "btc_usd", Type: "sell",
+		Amount: 2, Rate: 100, Timestamp: 86400})
+	reports := b.Periods(Daily)
+	if len(reports) != 2 || reports[1].Sells != 1 || !near(reports[1].Realized, -100) {
+		t.Errorf("%+v", reports)
+	}
+	if p := b.Positions()["btc_usd"]; !near(p.Unmatched, 1) {
+		t.Error("unmatched", p.Unmatched)
+	}
+}
@@ -0,0 +1,347 @@
+package btce
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NonceStore keeps the nonce of an API key between runs.
+type NonceStore interface {
+	Load() (uint64, error)
+	Save(nonce uint64) error
+}
+
+// FileNonceStore keeps a nonce as a decimal number in a file. A
+// missing file means nonce 0.
+type FileNonceStore string
+
+// Load implements NonceStore.
+func (f FileNonceStore) Load() (uint64, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// Save implements NonceStore.
+func (f FileNonceStore) Save(nonce uint64) error {
+	return writePrivateFile(string(f), []byte(fmt.Sprintln(nonce)))
+}
+
+// RateLimiter spaces calls at least Interval apart.
+type RateLimiter struct {
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// Wait blocks until the next call is allowed.
+func (l *RateLimiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.Interval)
+	l.mu.Unlock()
+	time.Sleep(at.Sub(now))
+}
+
+// Middleware makes a client wait for the limiter before each call.
+func (l *RateLimiter) Middleware(next Invoker) Invoker {
+	return func(inv *Invocation) error {
+		l.Wait()
+		return next(inv)
+	}
+}
+
+// Account is a named API key with its own client, nonce store and
+// rate limiter.
+type Account struct {
+	Name    string
+	Client  *Client
+	Key     string       // key source for Client.LoadKey, loaded on first use; may be empty
+	Nonces  NonceStore   // may be nil
+	Limiter *RateLimiter // may be nil
+
+	loaded bool
+}
+
+// AccountOrder is an active order of an account.
+type AccountOrder struct {
+	Account string
+	Id      uint64
+	ActiveOrder
+}
+
+// Accounts is a registry of accounts, by name.
+type Accounts struct {
+	mu       sync.Mutex
+	accounts map[string]*Account
+}
+
+// NewAccounts returns an empty registry.
+func NewAccounts() *Accounts {
+	return &Accounts{accounts: map[string]*Account{}}
+}
+
+// AccountConfig describes an account in a config file for
+// LoadAccounts.
+type AccountConfig struct {
+	Key      string `json:"key"`      // key source for Client.LoadKey; files are relative to the config
+	Interval string `json:"interval"` // minimal interval between calls, like "500ms"
+}
+
+// DefaultAccountInterval is the minimal interval between calls of an
+// account loaded by LoadAccounts, unless its config has one.
+const DefaultAccountInterval = 200 * time.Millisecond
+
+// AccountsConfig is a config file for LoadAccounts.
+type AccountsConfig struct {
+	URL      string                   `json:"url"`
+	Interval string                   `json:"interval"` // default for accounts
+	Accounts map[string]AccountConfig `json:"accounts"`
+}
+
+// LoadAccounts loads accounts from a directory or a config file,
+// creating clients for the base URL (unless the config has its own).
+//
+// In a directory, each *.json file is a key file (maybe encrypted),
+// named after the account. A config file is AccountsConfig in JSON.
+// Either way, nonces are stored in <name>.nonce files next to keys
+// or to the config. Keys are only loaded when an account is used, so
+// no passphrase is asked for or command run for the others.
+func LoadAccounts(path string, url string) (*Accounts, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	config := AccountsConfig{URL: url, Accounts: map[string]AccountConfig{}}
+	dir := path
+	if fi.IsDir() {
+		files, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := strings.TrimSuffix(filepath.Base(file), ".json")
+			config.Accounts[name] = AccountConfig{Key: file}
+		}
+	} else {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		if config.URL == "" {
+			config.URL = url
+		}
+		dir = filepath.Dir(path)
+		for name, ac := range config.Accounts {
+			if isKeyFile(ac.Key) && !filepath.IsAbs(ac.Key) {
+				ac.Key = filepath.Join(dir, ac.Key)
+				config.Accounts[name] = ac
+			}
+		}
+	}
+	a := NewAccounts()
+	for name, ac := range config.Accounts {
+		interval := ac.Interval
+		if interval == "" {
+			interval = config.Interval
+		}
+		limiter := &RateLimiter{Interval: DefaultAccountInterval}
+		if interval != "" {
+			d, err := time.ParseDuration(interval)
+			if err != nil {
+				return nil, fmt.Errorf("Account %v: %v", name, err)
+			}
+			limiter.Interval = d
+		}
+		nonces := FileNonceStore(filepath.Join(dir, name+".nonce"))
+		if err := a.Add(&Account{Name: name, Client: &Client{URL: config.URL},
+			Key: ac.Key, Nonces: nonces, Limiter: limiter}); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// isKeyFile tells whether a key source for Client.LoadKey is a file.
+func isKeyFile(source string) bool {
+	return source != "env" && !strings.HasPrefix(source, "env:") &&
+		!strings.HasPrefix(source, "cmd:")
+}
+
+// Add registers an account, wiring its nonce store and rate limiter
+// into the client. On first use of the account, its key is loaded
+// (if set) and the stored nonce is used if it's ahead of the key's;
+// the nonce is saved after each private call.
+func (a *Accounts) Add(account *Account) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.accounts[account.Name]; ok {
+		return fmt.Errorf("Duplicate account: %v", account.Name)
+	}
+	c := account.Client
+	if account.Nonces != nil {
+		c.Use(func(next Invoker) Invoker {
+			return func(inv *Invocation) error {
+				err := next(inv)
+				if inv.Private {
+					if serr := account.Nonces.Save(c.Auth.Nonce); err == nil {
+						err = serr
+					}
+				}
+				return err
+			}
+		})
+	}
+	if account.Limiter != nil {
+		c.Use(account.Limiter.Middleware)
+	}
+	a.accounts[account.Name] = account
+	return nil
+}
+
+// Names returns account names, sorted.
+func (a *Accounts) Names() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	names := make([]string, 0, len(a.accounts))
+	for name := range a.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns an account by name, loading its key and nonce if it's
+// not used yet.
+func (a *Accounts) Get(name string) (*Account, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	account, ok := a.accounts[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown account: %v", name)
+	}
+	if !account.loaded {
+		if err := account.load(); err != nil {
+			return nil, fmt.Errorf("Account %v: %v", name, err)
+		}
+		account.loaded = true
+	}
+	return account, nil
+}
+
+func (account *Account) load() error {
+	c := account.Client
+	if account.Key != "" {
+		if err := c.LoadKey(account.Key); err != nil {
+			return err
+		}
+	}
+	if account.Nonces != nil {
+		nonce, err := account.Nonces.Load()
+		if err != nil {
+			return err
+		}
+		if nonce > c.Auth.Nonce {
+			c.Auth.Nonce = nonce
+		}
+	}
+	return nil
+}
+
+// Client returns the client of a named account.
+func (a *Accounts) Client(name string) (*Client, error) {
+	account, err := a.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return account.Client, nil
+}
+
+// Balances refreshes balances of all accounts, returning totals by
+// currency and balances by account.
+func (a *Accounts) Balances() (map[string]Balance, map[string]map[string]Balance, error) {
+	total := map[string]Balance{}
+	byAccount := map[string]map[string]Balance{}
+	for _, name := range a.Names() {
+		c, err := a.Client(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.RefreshBalances(); err != nil {
+			return nil, nil, fmt.Errorf("Account %v: %v", name, err)
+		}
+		balances := c.Balances.All()
+		byAccount[name] = balances
+		for currency, b := range balances {
+			t := total[currency]
+			t.Available += b.Available
+			t.Reserved += b.Reserved
+			total[currency] = t
+		}
+	}
+	return total, byAccount, nil
+}
+
+// Orders returns active orders of all accounts for a pair (all pairs
+// if empty), ordered by account and id.
+func (a *Accounts) Orders(pair string) ([]AccountOrder, error) {
+	result := []AccountOrder{}
+	for _, name := range a.Names() {
+		c, err := a.Client(name)
+		if err != nil {
+			return nil, err
+		}
+		orders, err := c.GetActiveOrders(pair)
+		if err != nil {
+			return nil, fmt.Errorf("Account %v: %v", name, err)
+		}
+		ids := make([]uint64, 0, len(orders))
+		for id := range orders {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			result = append(result, AccountOrder{name, id, orders[id]})
+		}
+	}
+	return result, nil
+}
+
+// Trade places an order on a named account.
+func (a *Accounts) Trade(name string, p TradeParameters) (TradeResult, error) {
+	c, err := a.Client(name)
+	if err != nil {
+		return TradeResult{}, err
+	}
+	return c.PlaceOrder(p)
+}
+
+// Cancel cancels an order of a named account.
+func (a *Accounts) Cancel(name string, orderId uint64) (CancelOrderResult, error) {
+	c, err := a.Client(name)
+	if err != nil {
+		return CancelOrderResult{}, err
+	}
+	return c.Cancel(orderId)
+}
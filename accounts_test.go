@@ -0,0 +1,117 @@
+package btce
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccounts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "main.json"), []byte(`{"key":"K1","secret":"S1"}`), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "bot.json"), []byte(`{"key":"K2","secret":"S2"}`), 0600)
+	FileNonceStore(filepath.Join(dir, "bot.nonce")).Save(41)
+	a, err := LoadAccounts(dir, "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := a.Names(); len(names) != 2 || names[0] != "bot" {
+		t.Fatal(names)
+	}
+
+	exchanges := map[string]*fakeExchange{}
+	for i, name := range a.Names() {
+		f := newFakeExchange()
+		defer f.Close()
+		usd := float64(100 * (i + 1))
+		f.Methods["getInfo"] = func(url.Values) (interface{}, error) {
+			return GetInfoResult{Funds: map[string]float64{"usd": usd}}, nil
+		}
+		f.Methods["ActiveOrders"] = func(url.Values) (interface{}, error) {
+			return ActiveOrdersResult{uint64(i + 1): {Pair: "btc_usd", Type: "buy", Amount: 1, Rate: 10}}, nil
+		}
+		f.Methods["Trade"] = func(v url.Values) (interface{}, error) {
+			return TradeResult{OrderId: 9}, nil
+		}
+		exchanges[name] = f
+		account, _ := a.Get(name)
+		account.Limiter.Interval = 0
+		c := account.Client
+		c.URL = f.URL
+		c.Retries = &Retries{}
+	}
+	var nonce string
+	exchanges["bot"].Methods["getInfo"] = func(v url.Values) (interface{}, error) {
+		nonce = v.Get("nonce")
+		return GetInfoResult{Funds: map[string]float64{"usd": 100}}, nil
+	}
+
+	total, byAccount, err := a.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total["usd"].Available != 300 || total["usd"].Reserved != 20 ||
+		byAccount["main"]["usd"].Available != 200 {
+		t.Errorf("balances: %+v %+v", total, byAccount)
+	}
+	if nonce != "41" {
+		t.Error("stored nonce not used:", nonce)
+	}
+	if stored, _ := FileNonceStore(filepath.Join(dir, "bot.nonce")).Load(); stored != 43 {
+		t.Error("nonce not saved:", stored)
+	}
+
+	orders, err := a.Orders("")
+	if err != nil || len(orders) != 2 || orders[1].Account != "main" || orders[1].Id != 2 {
+		t.Errorf("orders: %+v %v", orders, err)
+	}
+	if _, err := a.Trade("main", TradeParameters{Pair: "btc_usd", Type: "buy", Rate: 1, Amount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if calls := exchanges["main"].Calls; calls[len(calls)-1] != "Trade" {
+		t.Error("trade not routed to main:", calls)
+	}
+	if _, err := a.Trade("nobody", TradeParameters{}); err == nil {
+		t.Error("expected an error for unknown account")
+	}
+}
+
+func TestAccountsConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "keys"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "keys", "main.json"), []byte(`{"key":"K1","secret":"S1"}`), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "keys", "bot.json"), []byte(`{"key":"K2","secret":"S2"}`), 0600)
+	config := filepath.Join(dir, "accounts.json")
+	ioutil.WriteFile(config, []byte(`{"accounts": {
+"main": {"key": "keys/main.json"},
+"bot": {"key": "keys/bot.json", "interval": "1s"},
+"broken": {"key": "keys/missing.json"}}}`), 0600)
+	a, err := LoadAccounts(config, "http://localhost")
+	if err != nil {
+		t.Fatal("unused account loaded:", err)
+	}
+	c, err := a.Client("main")
+	if err != nil || c.Auth.Key != "K1" {
+		t.Fatal("key path not relative to config:", err)
+	}
+	if _, err := a.Client("broken"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+	main, _ := a.Get("main")
+	bot, _ := a.Get("bot")
+	if main.Limiter == nil || main.Limiter.Interval != DefaultAccountInterval ||
+		bot.Limiter.Interval != time.Second {
+		t.Error("rate limits:", main.Limiter, bot.Limiter)
+	}
+}
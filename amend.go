@@ -0,0 +1,97 @@
+package btce
+
+import (
+	"fmt"
+)
+
+// AmendResult describes both legs of Amend.
+type AmendResult struct {
+	Original    OrderInfo         // original order, as of after cancellation
+	Cancel      CancelOrderResult // result of cancellation
+	Filled      float64           // original amount executed before cancellation
+	Amount      float64           // amount of the replacement order
+	Replacement *TradeResult      // nil if nothing was placed
+	RolledBack  *TradeResult      // original remainder placed again on failure
+}
+
+// Amend replaces an active order with one at a different rate and,
+// optionally, a different amount. The exchange can't do it, so Amend
+// cancels the order, checks with OrderInfo how much of it was
+// executed before the cancellation, and places a replacement for the
+// rest.
+//
+// newAmount is the desired total amount of the order, including what
+// was already executed (zero keeps the original total); the
+// replacement is placed for newAmount minus the executed part. When
+// that is below the pair's minimum amount, nothing is placed.
+//
+// If placing the replacement fails, the unexecuted remainder of the
+// original order is placed again at the original rate (RolledBack),
+// and the placement error is returned with the result.
+func (c *Client) Amend(orderId uint64, newRate float64, newAmount float64) (*AmendResult, error) {
+	before, err := c.orderInfo(orderId)
+	if err != nil {
+		return nil, err
+	}
+	if before.Status != 0 {
+		return nil, fmt.Errorf("Order #%v is not active (status %v)",
+			orderId, before.Status)
+	}
+	result := &AmendResult{}
+	err = c.Call(CancelOrderParameters{OrderId: orderId}, &result.Cancel)
+	if err != nil {
+		return nil, err
+	}
+	result.Original, err = c.orderInfo(orderId)
+	if err != nil {
+		return result, err
+	}
+	result.Filled = result.Original.StartAmount - result.Original.Amount
+	if newAmount == 0 {
+		newAmount = result.Original.StartAmount
+	}
+	result.Amount = newAmount - result.Filled
+
+	info, err := c.GetPublicInfo()
+	if err != nil {
+		return result, err
+	}
+	if result.Amount < info.Pairs[result.Original.Pair].MinAmount {
+		result.Amount = 0
+		return result, nil
+	}
+	replacement := TradeResult{}
+	err = c.Call(TradeParameters{Pair: result.Original.Pair,
+		Type: result.Original.Type, Rate: newRate,
+		Amount: result.Amount}, &replacement)
+	if err == nil {
+		result.Replacement = &replacement
+		return result, nil
+	}
+	if result.Original.Amount <= 0 {
+		return result, err
+	}
+	rollback := TradeResult{}
+	rerr := c.Call(TradeParameters{Pair: result.Original.Pair,
+		Type: result.Original.Type, Rate: result.Original.Rate,
+		Amount: result.Original.Amount}, &rollback)
+	if rerr != nil {
+		return result, fmt.Errorf("Replacement failed: %v; rollback failed: %v",
+			err, rerr)
+	}
+	result.RolledBack = &rollback
+	return result, err
+}
+
+// orderInfo returns information on a single order.
+func (c *Client) orderInfo(orderId uint64) (OrderInfo, error) {
+	info := OrderInfoResult{}
+	if err := c.Call(OrderInfoParameters{OrderId: orderId}, &info); err != nil {
+		return OrderInfo{}, err
+	}
+	order, ok := info[orderId]
+	if !ok {
+		return order, fmt.Errorf("Order #%v not found", orderId)
+	}
+	return order, nil
+}
@@ -0,0 +1,37 @@
+package btce
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestAmendRollback(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	status := uint(0)
+	f.Methods["OrderInfo"] = func(url.Values) (interface{}, error) {
+		return OrderInfoResult{3: {Pair: "btc_usd", Type: "sell",
+			StartAmount: 1, Amount: 0.6, Rate: 200, Status: status}}, nil
+	}
+	f.Methods["CancelOrder"] = func(url.Values) (interface{}, error) {
+		status = 3
+		return CancelOrderResult{OrderId: 3}, nil
+	}
+	amounts := []string{}
+	f.Methods["Trade"] = func(v url.Values) (interface{}, error) {
+		amounts = append(amounts, v.Get("amount"))
+		if v.Get("rate") == "150.000" {
+			return nil, errors.New("Price per BTC must be greater than 160 USD.")
+		}
+		return TradeResult{OrderId: 4, Remains: 0.6}, nil
+	}
+	r, err := f.client().Amend(3, 150, 2)
+	if err == nil || r == nil || r.RolledBack == nil {
+		t.Fatalf("expected rollback, got %+v, %v", r, err)
+	}
+	if r.Filled != 0.4 || len(amounts) != 2 ||
+		amounts[0] != "1.60000000" || amounts[1] != "0.60000000" {
+		t.Errorf("filled %v, trade amounts %v", r.Filled, amounts)
+	}
+}
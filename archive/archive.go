@@ -0,0 +1,303 @@
+// Package archive keeps a local, append-only copy of the private
+// trade and transaction history, synced incrementally from the
+// server, so reporting and accounting can work offline against data
+// already fetched once.
+//
+// An archive lives in a directory holding trades.jsonl and
+// transactions.jsonl (one JSON record per line) and cursor.json,
+// remembering the highest synced id for each kind of history. Use a
+// separate directory for each API key.
+package archive
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// PageSize is the number of history items requested per remote call
+// while syncing.
+const PageSize = 1000
+
+const (
+	tradesFile       = "trades.jsonl"
+	transactionsFile = "transactions.jsonl"
+	cursorFile       = "cursor.json"
+)
+
+// Trade is a TradeHistory item stored along with its id.
+type Trade struct {
+	Id uint64 `json:"id"`
+	btce.TradeHistoryItem
+}
+
+// Time returns the trade timestamp as time.Time.
+func (t Trade) Time() time.Time { return time.Unix(t.Timestamp, 0) }
+
+// Transaction is a TransHistory item stored along with its id.
+type Transaction struct {
+	Id uint64 `json:"id"`
+	btce.TransHistoryItem
+}
+
+// Time returns the transaction timestamp as time.Time.
+func (t Transaction) Time() time.Time { return time.Unix(t.Timestamp, 0) }
+
+// Cursor holds the highest synced id for each kind of history.
+type Cursor struct {
+	Trades       uint64 `json:"trades"`
+	Transactions uint64 `json:"transactions"`
+}
+
+// Query selects archived records. Empty Pair or Currency and zero
+// Since or Until mean no filtering by that criterion; Until is
+// exclusive.
+type Query struct {
+	Pair     string // trades only
+	Currency string // transactions only
+	Since    time.Time
+	Until    time.Time
+}
+
+func (q Query) matchTime(t time.Time) bool {
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Archive is a local trade and transaction history store.
+type Archive struct {
+	Dir    string
+	Client *btce.Client // used by Sync only
+	Cursor Cursor
+}
+
+// Open opens (creating when necessary) an archive in dir. Client may
+// be nil if the archive is only going to be queried.
+func Open(dir string, client *btce.Client) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	a := &Archive{Dir: dir, Client: client}
+	data, err := ioutil.ReadFile(a.path(cursorFile))
+	if err == nil {
+		err = json.Unmarshal(data, &a.Cursor)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Archive) path(name string) string {
+	return filepath.Join(a.Dir, name)
+}
+
+func (a *Archive) saveCursor() error {
+	data, err := json.Marshal(a.Cursor)
+	if err != nil {
+		return err
+	}
+	tempFile := a.path(cursorFile + ".tmpnew")
+	if err := ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, a.path(cursorFile))
+}
+
+// appendRecords writes records to the end of a JSONL file, syncing
+// it to disk before the cursor is advanced.
+func (a *Archive) appendRecords(name string, records []interface{}) error {
+	file, err := os.OpenFile(a.path(name),
+		os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, record := range records {
+		if err = encoder.Encode(record); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Sync fetches trades and transactions newer than the cursor,
+// appending them to the archive. It returns the number of new trades
+// and transactions stored; on error, everything stored before the
+// failure is kept and the next Sync continues from there.
+func (a *Archive) Sync() (trades int, transactions int, err error) {
+	trades, err = a.syncTrades()
+	if err != nil {
+		return
+	}
+	transactions, err = a.syncTransactions()
+	return
+}
+
+func (a *Archive) syncTrades() (int, error) {
+	total := 0
+	for {
+		p := btce.TradeHistoryParameters{
+			FromId: a.Cursor.Trades + 1,
+			Count:  PageSize,
+			Order:  "ASC",
+		}
+		result := btce.TradeHistoryResult{}
+		if err := a.Client.Call(p, &result); err != nil {
+			return total, err
+		}
+		ids := []uint64{}
+		for id := range result {
+			if id > a.Cursor.Trades {
+				ids = append(ids, id)
+			}
+		}
+		sortIds(ids)
+		if len(ids) == 0 {
+			return total, nil
+		}
+		records := make([]interface{}, len(ids))
+		for i, id := range ids {
+			records[i] = Trade{Id: id, TradeHistoryItem: result[id]}
+		}
+		if err := a.appendRecords(tradesFile, records); err != nil {
+			return total, err
+		}
+		a.Cursor.Trades = ids[len(ids)-1]
+		if err := a.saveCursor(); err != nil {
+			return total, err
+		}
+		total += len(ids)
+		if len(result) < PageSize {
+			return total, nil
+		}
+	}
+}
+
+func (a *Archive) syncTransactions() (int, error) {
+	total := 0
+	for {
+		p := btce.TransHistoryParameters{
+			FromId: a.Cursor.Transactions + 1,
+			Count:  PageSize,
+			Order:  "ASC",
+		}
+		result := btce.TransHistoryResult{}
+		if err := a.Client.Call(p, &result); err != nil {
+			return total, err
+		}
+		ids := []uint64{}
+		for id := range result {
+			if id > a.Cursor.Transactions {
+				ids = append(ids, id)
+			}
+		}
+		sortIds(ids)
+		if len(ids) == 0 {
+			return total, nil
+		}
+		records := make([]interface{}, len(ids))
+		for i, id := range ids {
+			records[i] = Transaction{Id: id, TransHistoryItem: result[id]}
+		}
+		if err := a.appendRecords(transactionsFile, records); err != nil {
+			return total, err
+		}
+		a.Cursor.Transactions = ids[len(ids)-1]
+		if err := a.saveCursor(); err != nil {
+			return total, err
+		}
+		total += len(ids)
+		if len(result) < PageSize {
+			return total, nil
+		}
+	}
+}
+
+func sortIds(ids []uint64) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+}
+
+// readRecords decodes every record of a JSONL file, calling fn for
+// each one. A missing file is an empty archive.
+func (a *Archive) readRecords(name string, fn func(*json.Decoder) error) error {
+	file, err := os.Open(a.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for {
+		err := fn(decoder)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Trades returns archived trades matching q, ordered by id.
+func (a *Archive) Trades(q Query) ([]Trade, error) {
+	result := []Trade{}
+	seen := map[uint64]bool{}
+	err := a.readRecords(tradesFile, func(d *json.Decoder) error {
+		var t Trade
+		if err := d.Decode(&t); err != nil {
+			return err
+		}
+		if seen[t.Id] || (q.Pair != "" && q.Pair != t.Pair) ||
+			!q.matchTime(t.Time()) {
+			return nil
+		}
+		seen[t.Id] = true
+		result = append(result, t)
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result, err
+}
+
+// Transactions returns archived transactions matching q, ordered by
+// id.
+func (a *Archive) Transactions(q Query) ([]Transaction, error) {
+	result := []Transaction{}
+	seen := map[uint64]bool{}
+	err := a.readRecords(transactionsFile, func(d *json.Decoder) error {
+		var t Transaction
+		if err := d.Decode(&t); err != nil {
+			return err
+		}
+		if seen[t.Id] || (q.Currency != "" && q.Currency != t.Currency) ||
+			!q.matchTime(t.Time()) {
+			return nil
+		}
+		seen[t.Id] = true
+		result = append(result, t)
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result, err
+}
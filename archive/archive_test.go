@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// fakeHistory serves public info and a TradeHistory of n trades
+// (ids 1..n), honoring from_id and count.
+func fakeHistory(n *uint64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/3/info" {
+			fmt.Fprint(w, `{"server_time":0,"pairs":{"btc_usd":{"decimal_places":3}}}`)
+			return
+		}
+		r.ParseForm()
+		from, _ := strconv.ParseUint(r.Form.Get("from_id"), 10, 64)
+		count, _ := strconv.ParseUint(r.Form.Get("count"), 10, 64)
+		result := map[uint64]btce.TradeHistoryItem{}
+		if r.Form.Get("method") == "TradeHistory" {
+			for id := from; id <= *n && uint64(len(result)) < count; id++ {
+				result[id] = btce.TradeHistoryItem{Pair: "btc_usd",
+					Type: "buy", Amount: 1, Rate: 100, Timestamp: int64(id)}
+			}
+		}
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(w, `{"success":1,"return":%s}`, data)
+	}))
+}
+
+func TestSyncIncremental(t *testing.T) {
+	n := uint64(3)
+	server := fakeHistory(&n)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := Open(dir, &btce.Client{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trades, _, err := a.Sync()
+	if err != nil || trades != 3 {
+		t.Fatal("first sync:", trades, err)
+	}
+	n = 5
+	a, err = Open(dir, &btce.Client{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trades, _, err = a.Sync()
+	if err != nil || trades != 2 || a.Cursor.Trades != 5 {
+		t.Fatal("second sync:", trades, a.Cursor, err)
+	}
+	stored, err := a.Trades(Query{Pair: "btc_usd"})
+	if err != nil || len(stored) != 5 {
+		t.Fatal("query:", len(stored), err)
+	}
+	for i, trade := range stored {
+		if trade.Id != uint64(i+1) {
+			t.Error("unexpected order of ids:", trade.Id)
+		}
+	}
+}
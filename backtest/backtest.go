@@ -0,0 +1,169 @@
+// Package backtest runs trading strategies over recorded market data.
+//
+// Events (tickers, depth snapshots and trades) are replayed in
+// simulated time through a paper.Exchange, so a strategy talks to an
+// ordinary *btce.Client whose public and private methods answer from
+// the replayed market and the simulated account. Fills, equity curve,
+// PnL and drawdown are reported at the end.
+package backtest
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+	"github.com/akovalenko/go-btce/accounting"
+	"github.com/akovalenko/go-btce/paper"
+)
+
+// Strategy is run by the backtest. Step is called after market
+// events, with the simulated time; returning an error stops the
+// backtest.
+type Strategy interface {
+	Step(c *btce.Client, now time.Time) error
+}
+
+// StrategyFunc adapts a function to Strategy.
+type StrategyFunc func(c *btce.Client, now time.Time) error
+
+func (f StrategyFunc) Step(c *btce.Client, now time.Time) error { return f(c, now) }
+
+// Backtest describes a backtest run.
+type Backtest struct {
+	Events   []Event            // ordered by time
+	Info     *btce.PublicInfo   // pair limits and fees, if not in Events
+	Funds    map[string]float64 // initial funds
+	Currency string             // currency for equity valuation
+	Interval time.Duration      // minimum simulated time between steps
+	Method   accounting.Method  // lot matching for per-pair PnL
+	Strategy Strategy
+}
+
+// EquityPoint is account value at a moment.
+type EquityPoint struct {
+	Time   time.Time
+	Equity float64
+}
+
+// Report is the result of a backtest.
+type Report struct {
+	Start       time.Time
+	End         time.Time
+	Steps       int
+	Funds       map[string]float64 // final funds, including reserved
+	Fills       []btce.TradeHistoryItem
+	Equity      []EquityPoint
+	StartEquity float64 // initial funds at rates of the first step
+	EndEquity   float64
+	PnL         float64 // EndEquity - StartEquity
+	MaxDrawdown float64 // largest drop from a peak, as a fraction
+	Pairs       []accounting.PairReport
+}
+
+// Run replays events, calling the strategy, and reports results.
+// Funds in currencies that can't be converted to Currency (no pair
+// with a known rate) are left out of equity.
+func (b *Backtest) Run() (*Report, error) {
+	if len(b.Events) == 0 {
+		return nil, errors.New("No events to replay")
+	}
+	replay := NewReplay(b.Info)
+	exchange := paper.New(replay, b.Funds)
+	client := &btce.Client{Backend: exchange}
+	report := &Report{Start: b.Events[0].Time}
+	peak := 0.0
+	var lastStep time.Time
+	for i, e := range b.Events {
+		replay.Apply(e)
+		last := i == len(b.Events)-1
+		if !last && b.Events[i+1].Time.Equal(e.Time) {
+			continue // apply all simultaneous events first
+		}
+		if report.Steps > 0 && e.Time.Sub(lastStep) < b.Interval && !last {
+			continue
+		}
+		if report.Steps == 0 {
+			report.StartEquity = b.value(replay, b.Funds)
+			peak = report.StartEquity
+		}
+		if err := b.Strategy.Step(client, e.Time); err != nil {
+			return report, err
+		}
+		lastStep = e.Time
+		report.Steps++
+		equity := b.equity(replay, exchange)
+		report.Equity = append(report.Equity, EquityPoint{e.Time, equity})
+		if equity > peak {
+			peak = equity
+		}
+		if peak > 0 && (peak-equity)/peak > report.MaxDrawdown {
+			report.MaxDrawdown = (peak - equity) / peak
+		}
+	}
+	report.End = b.Events[len(b.Events)-1].Time
+	report.Funds = totalFunds(exchange)
+	report.EndEquity = report.Equity[len(report.Equity)-1].Equity
+	report.PnL = report.EndEquity - report.StartEquity
+
+	ids := []uint64{}
+	for id := range exchange.State.Trades {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		report.Fills = append(report.Fills, exchange.State.Trades[id])
+	}
+	info, err := replay.Info()
+	if err != nil {
+		return report, err
+	}
+	book := accounting.NewBook(b.Method, info.Pairs)
+	book.AddHistory(exchange.State.Trades)
+	tickers := map[string]btce.TickerInfo{}
+	for pair := range book.Positions() {
+		tickers[pair] = btce.TickerInfo{Last: replay.Rate(pair)}
+	}
+	report.Pairs = book.Mark(tickers)
+	return report, nil
+}
+
+// totalFunds returns available funds plus funds reserved by orders.
+func totalFunds(exchange *paper.Exchange) map[string]float64 {
+	funds := map[string]float64{}
+	for currency, amount := range exchange.State.Funds {
+		funds[currency] += amount
+	}
+	for _, o := range exchange.State.Orders {
+		if o.Status != 0 {
+			continue
+		}
+		i := strings.Index(o.Pair, "_")
+		if o.Type == "buy" {
+			funds[o.Pair[i+1:]] += o.Amount * o.Rate
+		} else {
+			funds[o.Pair[:i]] += o.Amount
+		}
+	}
+	return funds
+}
+
+func (b *Backtest) equity(replay *Replay, exchange *paper.Exchange) float64 {
+	return b.value(replay, totalFunds(exchange))
+}
+
+// value converts funds to Currency at current rates.
+func (b *Backtest) value(replay *Replay, funds map[string]float64) float64 {
+	total := 0.0
+	for currency, amount := range funds {
+		if currency == b.Currency {
+			total += amount
+		} else if rate := replay.Rate(currency + "_" + b.Currency); rate != 0 {
+			total += amount * rate
+		} else if rate := replay.Rate(b.Currency + "_" + currency); rate != 0 {
+			total += amount / rate
+		}
+	}
+	return total
+}
@@ -0,0 +1,47 @@
+package backtest
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+const data = `{"time":"2017-01-01T00:00:00Z","info":{"pairs":{"btc_usd":{"decimal_places":3,"min_amount":0.01,"max_price":10000}}}}
+{"time":"2017-01-01T00:00:00Z","pair":"btc_usd","depth":{"asks":[[100,5]],"bids":[[99,5]]}}
+{"time":"2017-01-01T00:01:00Z","pair":"btc_usd","depth":{"asks":[[81,5]],"bids":[[79,5]]}}
+{"time":"2017-01-01T00:02:00Z","pair":"btc_usd","depth":{"asks":[[121,5]],"bids":[[119,5]]}}
+`
+
+func TestBuyAndHold(t *testing.T) {
+	events, err := ReadEvents(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bought := false
+	b := &Backtest{Events: events, Currency: "usd",
+		Funds: map[string]float64{"usd": 100},
+		Strategy: StrategyFunc(func(c *btce.Client, now time.Time) error {
+			if !bought {
+				bought = true
+				return c.Call(btce.TradeParameters{Pair: "btc_usd",
+					Type: "buy", Rate: 100, Amount: 1}, &btce.TradeResult{})
+			}
+			return nil
+		})}
+	r, err := b.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Steps != 3 || len(r.Fills) != 1 || r.Funds["btc"] != 1 {
+		t.Fatalf("%+v", r)
+	}
+	if math.Abs(r.PnL-20) > 1e-9 || math.Abs(r.MaxDrawdown-0.2) > 1e-9 {
+		t.Error("PnL", r.PnL, "drawdown", r.MaxDrawdown)
+	}
+	if len(r.Pairs) != 1 || r.Pairs[0].Unrealized != 20 {
+		t.Errorf("%+v", r.Pairs)
+	}
+}
@@ -0,0 +1,76 @@
+package backtest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Event is a market observation at a moment: public info, a ticker,
+// a depth snapshot or recent trades of a pair (any of them may be
+// missing). Recorded market data is a sequence of events, stored as
+// JSON lines.
+type Event struct {
+	Time   time.Time        `json:"time"`
+	Pair   string           `json:"pair,omitempty"`
+	Info   *btce.PublicInfo `json:"info,omitempty"`
+	Ticker *btce.TickerInfo `json:"ticker,omitempty"`
+	Depth  *btce.DepthInfo  `json:"depth,omitempty"`
+	Trades []btce.TradeInfo `json:"trades,omitempty"`
+}
+
+// ReadEvents decodes events from JSON lines. Data cut short (by a
+// recorder that died while writing) ends the events read, like EOF.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	events := []Event{}
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var e Event
+		err := decoder.Decode(&e)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// LoadFiles reads events from files (gzip-compressed if their names
+// end with ".gz") and returns them ordered by time.
+func LoadFiles(fileNames ...string) ([]Event, error) {
+	events := []Event{}
+	for _, fileName := range fileNames {
+		file, err := os.Open(fileName)
+		if err != nil {
+			return nil, err
+		}
+		var r io.Reader = file
+		if strings.HasSuffix(fileName, ".gz") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+			r = gz
+		}
+		more, err := ReadEvents(r)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, more...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
@@ -0,0 +1,106 @@
+package backtest
+
+import (
+	"errors"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// TradesKept is the number of recent trades per pair that Replay
+// remembers and returns.
+var TradesKept = 1000
+
+// Replay is a paper.Market presenting the state of the market as of
+// the last event applied.
+type Replay struct {
+	info    *btce.PublicInfo
+	now     time.Time
+	tickers map[string]btce.TickerInfo
+	depths  map[string]btce.DepthInfo
+	trades  map[string][]btce.TradeInfo // newest first
+}
+
+// NewReplay creates an empty Replay; info may be nil if events carry
+// it.
+func NewReplay(info *btce.PublicInfo) *Replay {
+	return &Replay{info: info,
+		tickers: map[string]btce.TickerInfo{},
+		depths:  map[string]btce.DepthInfo{},
+		trades:  map[string][]btce.TradeInfo{}}
+}
+
+// Apply makes an event the current market state.
+func (r *Replay) Apply(e Event) {
+	r.now = e.Time
+	if e.Info != nil {
+		r.info = e.Info
+	}
+	if e.Ticker != nil {
+		r.tickers[e.Pair] = *e.Ticker
+	}
+	if e.Depth != nil {
+		r.depths[e.Pair] = *e.Depth
+	}
+	if len(e.Trades) > 0 {
+		r.addTrades(e.Pair, e.Trades)
+	}
+}
+
+// addTrades merges trades not seen before, keeping the newest first.
+func (r *Replay) addTrades(pair string, trades []btce.TradeInfo) {
+	known := r.trades[pair]
+	newest := uint64(0)
+	if len(known) > 0 {
+		newest = known[0].TradeId
+	}
+	fresh := []btce.TradeInfo{}
+	for _, t := range trades {
+		if t.TradeId > newest {
+			fresh = append(fresh, t)
+		}
+	}
+	// recorded trades come newest first, like the "trades" method
+	merged := append(fresh, known...)
+	if len(merged) > TradesKept {
+		merged = merged[:TradesKept]
+	}
+	r.trades[pair] = merged
+}
+
+func (r *Replay) Info() (*btce.PublicInfo, error) {
+	if r.info == nil {
+		return nil, errors.New("No public info in replayed data")
+	}
+	return r.info, nil
+}
+
+func (r *Replay) Ticker(pair string) (btce.TickerInfo, error) {
+	return r.tickers[pair], nil
+}
+
+func (r *Replay) Depth(pair string) (btce.DepthInfo, error) {
+	return r.depths[pair], nil
+}
+
+func (r *Replay) Trades(pair string) ([]btce.TradeInfo, error) {
+	return r.trades[pair], nil
+}
+
+func (r *Replay) Now() time.Time { return r.now }
+
+// Rate returns the current rate of a pair: the ticker's last rate,
+// the middle of the best bid and ask, or the last trade price,
+// whichever is known first. Zero means nothing is known.
+func (r *Replay) Rate(pair string) float64 {
+	if t := r.tickers[pair]; t.Last != 0 {
+		return t.Last
+	}
+	if d := r.depths[pair]; len(d.Asks) > 0 && len(d.Bids) > 0 {
+		return (d.Asks[0].Rate() + d.Bids[0].Rate()) / 2
+	}
+	if t := r.trades[pair]; len(t) > 0 {
+		return t[0].Price
+	}
+	return 0
+}
@@ -0,0 +1,224 @@
+package btce
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Balance of a single currency. Funds reported by the server are
+// Available; Reserved is what active orders hold.
+type Balance struct {
+	Available float64
+	Reserved  float64
+}
+
+// Total returns available and reserved funds together.
+func (b Balance) Total() float64 { return b.Available + b.Reserved }
+
+// BalanceEvent describes a change of a currency balance.
+type BalanceEvent struct {
+	Currency string
+	Old      Balance
+	New      Balance
+}
+
+// reservation is an amount of currency held by an active order.
+type reservation struct {
+	Pair     string
+	Currency string
+	Amount   float64
+}
+
+// Balances tracks account funds from results of private calls. When
+// assigned to client.Balances, it's updated by every successful Call
+// returning funds (getInfo, Trade, CancelOrder, WithdrawCoin,
+// CreateCoupon, RedeemCoupon) and by ActiveOrders, which determines
+// reserved amounts. Orders placed or cancelled with Trade and
+// CancelOrder are accounted immediately, without waiting for the next
+// ActiveOrders call.
+//
+// Balances is safe for concurrent use; change handlers are called
+// synchronously from the goroutine doing the update.
+type Balances struct {
+	mutex     sync.Mutex
+	available map[string]float64
+	orders    map[uint64]reservation
+	handlers  []func(BalanceEvent)
+}
+
+// NewBalances returns an empty balance tracker.
+func NewBalances() *Balances {
+	return &Balances{
+		available: map[string]float64{},
+		orders:    map[uint64]reservation{},
+	}
+}
+
+// OnChange registers a handler called for every currency whose
+// balance changes.
+func (b *Balances) OnChange(handler func(BalanceEvent)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *Balances) balance(currency string) Balance {
+	result := Balance{Available: b.available[currency]}
+	for _, r := range b.orders {
+		if r.Currency == currency {
+			result.Reserved += r.Amount
+		}
+	}
+	return result
+}
+
+func (b *Balances) all() map[string]Balance {
+	result := map[string]Balance{}
+	for currency := range b.available {
+		result[currency] = b.balance(currency)
+	}
+	for _, r := range b.orders {
+		result[r.Currency] = b.balance(r.Currency)
+	}
+	return result
+}
+
+// Get returns the balance of a currency.
+func (b *Balances) Get(currency string) Balance {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.balance(currency)
+}
+
+// All returns balances of all known currencies.
+func (b *Balances) All() map[string]Balance {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.all()
+}
+
+// update runs fn under lock, then reports changed balances to
+// handlers.
+func (b *Balances) update(fn func()) {
+	b.mutex.Lock()
+	old := b.all()
+	fn()
+	current := b.all()
+	handlers := b.handlers
+	b.mutex.Unlock()
+
+	for currency, balance := range current {
+		if old[currency] != balance {
+			for _, handler := range handlers {
+				handler(BalanceEvent{currency, old[currency], balance})
+			}
+		}
+	}
+	for currency, balance := range old {
+		if _, ok := current[currency]; !ok {
+			for _, handler := range handlers {
+				handler(BalanceEvent{currency, balance, Balance{}})
+			}
+		}
+	}
+}
+
+// SetFunds replaces available funds with those reported by the
+// server.
+func (b *Balances) SetFunds(funds map[string]float64) {
+	b.update(func() { b.setFunds(funds) })
+}
+
+func (b *Balances) setFunds(funds map[string]float64) {
+	if funds == nil {
+		return
+	}
+	b.available = make(map[string]float64, len(funds))
+	for currency, amount := range funds {
+		b.available[currency] = amount
+	}
+}
+
+// orderReservation computes what an order of a given type holds: the
+// base currency for sell orders and the quote currency for buy
+// orders.
+func orderReservation(pair, kind string, amount, rate float64) reservation {
+	currencies := strings.SplitN(pair, "_", 2)
+	if len(currencies) < 2 {
+		return reservation{Pair: pair}
+	}
+	if kind == "sell" {
+		return reservation{pair, currencies[0], amount}
+	}
+	return reservation{pair, currencies[1], amount * rate}
+}
+
+// SetOrders replaces reservations of active orders for a pair (or for
+// all pairs, if pair is empty) with those in orders.
+func (b *Balances) SetOrders(pair string, orders ActiveOrdersResult) {
+	b.update(func() {
+		for id, r := range b.orders {
+			if pair == "" || pair == r.Pair {
+				delete(b.orders, id)
+			}
+		}
+		for id, order := range orders {
+			b.orders[id] = orderReservation(order.Pair, order.Type,
+				order.Amount, order.Rate)
+		}
+	})
+}
+
+// observe updates balances from a successful private call with
+// parameters pstruct, decoded into dst.
+func (b *Balances) observe(pstruct interface{}, dst interface{}) {
+	switch p := pstruct.(type) {
+	case ActiveOrdersParameters:
+		if orders, ok := dst.(*ActiveOrdersResult); ok {
+			b.SetOrders(p.Pair, *orders)
+		}
+		return
+	case TradeParameters:
+		if r, ok := dst.(*TradeResult); ok {
+			b.update(func() {
+				b.setFunds(r.Funds)
+				if r.OrderId != 0 {
+					b.orders[r.OrderId] = orderReservation(p.Pair,
+						p.Type, r.Remains, p.Rate)
+				}
+			})
+			return
+		}
+	case CancelOrderParameters:
+		if r, ok := dst.(*CancelOrderResult); ok {
+			b.update(func() {
+				b.setFunds(r.Funds)
+				delete(b.orders, p.OrderId)
+			})
+			return
+		}
+	}
+	v := reflect.ValueOf(dst)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		funds := v.Elem().FieldByName("Funds")
+		if funds.IsValid() && funds.Kind() == reflect.Map && !funds.IsNil() {
+			if m, ok := funds.Interface().(map[string]float64); ok {
+				b.SetFunds(m)
+			}
+		}
+	}
+}
+
+// RefreshBalances queries getInfo and ActiveOrders, creating
+// c.Balances if it's nil, so the tracker starts from the complete
+// account state.
+func (c *Client) RefreshBalances() error {
+	if c.Balances == nil {
+		c.Balances = NewBalances()
+	}
+	if err := c.Call(GetInfoParameters{}, &GetInfoResult{}); err != nil {
+		return err
+	}
+	return c.Call(ActiveOrdersParameters{}, &ActiveOrdersResult{})
+}
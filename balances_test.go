@@ -0,0 +1,30 @@
+package btce
+
+import "testing"
+
+func TestBalancesObserve(t *testing.T) {
+	b := NewBalances()
+	events := 0
+	b.OnChange(func(BalanceEvent) { events++ })
+	b.observe(GetInfoParameters{},
+		&GetInfoResult{Funds: map[string]float64{"btc": 1, "usd": 100}})
+	b.observe(TradeParameters{Pair: "btc_usd", Type: "buy", Rate: 50, Amount: 1},
+		&TradeResult{OrderId: 7, Remains: 1,
+			Funds: map[string]float64{"btc": 1, "usd": 50}})
+	if usd := b.Get("usd"); usd.Available != 50 || usd.Total() != 100 {
+		t.Errorf("after trade: %+v", usd)
+	}
+	b.observe(CancelOrderParameters{OrderId: 7},
+		&CancelOrderResult{OrderId: 7, Funds: map[string]float64{"btc": 1, "usd": 100}})
+	if usd := b.Get("usd"); usd.Available != 100 || usd.Reserved != 0 {
+		t.Errorf("after cancel: %+v", usd)
+	}
+	b.observe(ActiveOrdersParameters{}, &ActiveOrdersResult{
+		9: {Pair: "btc_usd", Type: "sell", Amount: 0.5, Rate: 200}})
+	if btc := b.Get("btc"); btc.Reserved != 0.5 || btc.Total() != 1.5 {
+		t.Errorf("after ActiveOrders: %+v", btc)
+	}
+	if events != 5 {
+		t.Error("unexpected event count:", events)
+	}
+}
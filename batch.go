@@ -0,0 +1,121 @@
+package btce
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OrderFilter selects active orders. Zero values of fields mean no
+// filtering by that criterion.
+type OrderFilter struct {
+	Id        uint64
+	Pair      string
+	Type      string
+	MinRate   float64
+	MaxRate   float64
+	MinAmount float64
+	MaxAmount float64
+}
+
+// Match checks whether an active order satisfies the filter.
+func (f OrderFilter) Match(id uint64, order ActiveOrder) bool {
+	switch {
+	case f.Id != 0 && f.Id != id:
+	case f.Pair != "" && f.Pair != order.Pair:
+	case f.Type != "" && f.Type != order.Type:
+	case f.MinRate != 0 && f.MinRate > order.Rate:
+	case f.MaxRate != 0 && f.MaxRate < order.Rate:
+	case f.MinAmount != 0 && f.MinAmount > order.Amount:
+	case f.MaxAmount != 0 && f.MaxAmount < order.Amount:
+	default:
+		return true
+	}
+	return false
+}
+
+// Select returns ids of matching orders in ascending order.
+func (f OrderFilter) Select(orders ActiveOrdersResult) []uint64 {
+	result := []uint64{}
+	for id, order := range orders {
+		if f.Match(id, order) {
+			result = append(result, id)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// CancelResult is the outcome of cancelling one order in CancelAll.
+type CancelResult struct {
+	OrderId uint64
+	Order   ActiveOrder
+	Result  CancelOrderResult // zero in dry-run mode or on error
+	Err     error
+}
+
+// PlaceResult is the outcome of placing one order in PlaceMany.
+type PlaceResult struct {
+	Order  TradeParameters
+	Result TradeResult // zero in dry-run mode or on error
+	Err    error
+}
+
+// CancelAll cancels all active orders matching the filter, one by
+// one, continuing past failures; per-order errors are reported in
+// results. The returned error is set only when active orders can't
+// be listed. In dry-run mode, matching orders are reported without
+// being cancelled.
+func (c *Client) CancelAll(f OrderFilter, dryRun bool) ([]CancelResult, error) {
+	orders := ActiveOrdersResult{}
+	if err := c.Call(ActiveOrdersParameters{Pair: f.Pair}, &orders); err != nil {
+		return nil, err
+	}
+	results := []CancelResult{}
+	for _, id := range f.Select(orders) {
+		r := CancelResult{OrderId: id, Order: orders[id]}
+		if !dryRun {
+			r.Err = c.Call(CancelOrderParameters{OrderId: id}, &r.Result)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// PlaceMany places orders sequentially, continuing past failures.
+// Orders are checked against the pair's limits (minimum amount and
+// rate range) before placement; in dry-run mode, only that check is
+// done.
+func (c *Client) PlaceMany(orders []TradeParameters, dryRun bool) []PlaceResult {
+	results := make([]PlaceResult, len(orders))
+	for i, order := range orders {
+		results[i].Order = order
+		results[i].Err = c.CheckOrder(order)
+		if results[i].Err == nil && !dryRun {
+			results[i].Err = c.Call(order, &results[i].Result)
+		}
+	}
+	return results
+}
+
+// CheckOrder validates order parameters against public pair info,
+// without placing the order.
+func (c *Client) CheckOrder(p TradeParameters) error {
+	info, err := c.GetPublicInfo()
+	if err != nil {
+		return err
+	}
+	pi, ok := info.Pairs[p.Pair]
+	switch {
+	case !ok:
+		return fmt.Errorf("Unknown pair: %v", p.Pair)
+	case p.Type != "buy" && p.Type != "sell":
+		return fmt.Errorf("Order type: buy or sell expected, got %v", p.Type)
+	case p.Amount < pi.MinAmount:
+		return fmt.Errorf("Amount %v is below minimum %v for %v",
+			p.Amount, pi.MinAmount, p.Pair)
+	case p.Rate < pi.MinPrice || (pi.MaxPrice > 0 && p.Rate > pi.MaxPrice):
+		return fmt.Errorf("Rate %v is out of range %v..%v for %v",
+			p.Rate, pi.MinPrice, pi.MaxPrice, p.Pair)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package btce
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestCancelAllContinues(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	f.Methods["ActiveOrders"] = func(url.Values) (interface{}, error) {
+		return ActiveOrdersResult{
+			1: {Pair: "btc_usd", Type: "sell", Rate: 200, Amount: 1},
+			2: {Pair: "btc_usd", Type: "sell", Rate: 300, Amount: 1},
+			3: {Pair: "btc_usd", Type: "buy", Rate: 100, Amount: 1}}, nil
+	}
+	f.Methods["CancelOrder"] = func(v url.Values) (interface{}, error) {
+		if v.Get("order_id") == "1" {
+			return nil, errors.New("bad status")
+		}
+		return CancelOrderResult{}, nil
+	}
+	c := f.client()
+	results, err := c.CancelAll(OrderFilter{Type: "sell"}, true)
+	if err != nil || len(results) != 2 || len(f.Calls) != 1 {
+		t.Fatal("dry run:", results, err, f.Calls)
+	}
+	results, err = c.CancelAll(OrderFilter{Type: "sell"}, false)
+	if err != nil || len(results) != 2 {
+		t.Fatal(results, err)
+	}
+	if results[0].Err == nil || results[1].Err != nil {
+		t.Errorf("%+v", results)
+	}
+}
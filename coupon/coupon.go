@@ -0,0 +1,288 @@
+// Package coupon keeps track of BTC-e coupons in an encrypted local
+// ledger. Coupons are bearer instruments: a lost code is lost money,
+// so each created coupon is saved before Create returns, and its
+// redemption is detected later from the transaction history.
+package coupon
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Coupon statuses
+const (
+	Outstanding = "outstanding" // created, not redeemed yet
+	Redeemed    = "redeemed"    // created and redeemed by someone
+	Cancelled   = "cancelled"   // created and redeemed back by us
+	Received    = "received"    // redeemed by us, created elsewhere
+)
+
+// Coupon is a ledger entry.
+type Coupon struct {
+	Code     string    `json:"code"`
+	Currency string    `json:"currency"`
+	Amount   float64   `json:"amount"`
+	Receiver string    `json:"receiver,omitempty"`
+	TransId  uint64    `json:"trans_id"` // of creation (or redemption, if Received)
+	Created  time.Time `json:"created"`
+	Status   string    `json:"status"`
+	Changed  time.Time `json:"changed"` // when Status was set
+}
+
+// PageSize is the number of transactions requested at once by Sync.
+var PageSize uint = 1000
+
+// Ledger is a list of coupons kept in a file encrypted with a
+// passphrase (see btce.Encrypt).
+type Ledger struct {
+	File    string
+	Coupons []Coupon
+
+	passphrase []byte
+	now        func() time.Time
+}
+
+// LockTimeout is how long Save waits for another process saving the
+// same ledger.
+var LockTimeout = 10 * time.Second
+
+// Open reads a ledger, or starts an empty one if the file doesn't
+// exist.
+func Open(file string, passphrase []byte) (*Ledger, error) {
+	l := &Ledger{File: file, passphrase: passphrase, now: time.Now}
+	coupons, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	l.Coupons = coupons
+	return l, nil
+}
+
+// read returns coupons saved in the file, none if it doesn't exist.
+func (l *Ledger) read() ([]Coupon, error) {
+	data, err := ioutil.ReadFile(l.File)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = btce.Decrypt(data, l.passphrase); err != nil {
+		return nil, err
+	}
+	coupons := []Coupon{}
+	if err := json.Unmarshal(data, &coupons); err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// lock creates the lock file of the ledger, waiting up to LockTimeout
+// while another process has it, and returns a function removing it.
+func (l *Ledger) lock() (func(), error) {
+	lockFile := l.File + ".lock"
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		file, err := os.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockFile) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Ledger is locked by %v (remove it if no other process is saving the ledger)",
+				lockFile)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// merge adds coupons saved by another process that the ledger
+// doesn't have, and takes their later status for those it has.
+func (l *Ledger) merge(saved []Coupon) {
+	for _, coupon := range saved {
+		i := l.find(coupon.Code)
+		switch {
+		case i < 0:
+			l.Coupons = append(l.Coupons, coupon)
+		case coupon.Changed.After(l.Coupons[i].Changed):
+			l.Coupons[i] = coupon
+		}
+	}
+}
+
+// Save writes the ledger, readable by the owner only. The file is
+// locked meanwhile, and coupons saved to it by other processes since
+// Open are merged in first, so none of them is lost.
+func (l *Ledger) Save() error {
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	saved, err := l.read()
+	if err != nil {
+		return err
+	}
+	l.merge(saved)
+	data, err := json.Marshal(l.Coupons)
+	if err != nil {
+		return err
+	}
+	if data, err = btce.Encrypt(data, l.passphrase); err != nil {
+		return err
+	}
+	tempFile := l.File + ".tmpnew"
+	if err := ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, l.File)
+}
+
+// Create creates a coupon and saves it to the ledger. If saving
+// fails, the coupon is returned along with the error, so the caller
+// can keep the code in some other way.
+func (l *Ledger) Create(c *btce.Client, p btce.CreateCouponParameters) (Coupon, error) {
+	result := btce.CreateCouponResult{}
+	if err := c.Call(p, &result); err != nil {
+		return Coupon{}, err
+	}
+	now := l.now()
+	coupon := Coupon{Code: result.Coupon, Currency: p.Currency,
+		Amount: p.Amount, Receiver: p.Receiver, TransId: result.TransId,
+		Created: now, Status: Outstanding, Changed: now}
+	l.Coupons = append(l.Coupons, coupon)
+	if err := l.Save(); err != nil {
+		return coupon, fmt.Errorf("Coupon %v created but not saved: %v",
+			coupon.Code, err)
+	}
+	return coupon, nil
+}
+
+// Redeem redeems a coupon, recording it as Received, or as Cancelled
+// if it's our own outstanding coupon.
+func (l *Ledger) Redeem(c *btce.Client, code string) (Coupon, error) {
+	result := btce.RedeemCouponResult{}
+	if err := c.Call(btce.RedeemCouponParameters{Coupon: code}, &result); err != nil {
+		return Coupon{}, err
+	}
+	now := l.now()
+	var coupon Coupon
+	if i := l.find(code); i >= 0 {
+		l.Coupons[i].Status, l.Coupons[i].Changed = Cancelled, now
+		coupon = l.Coupons[i]
+	} else {
+		coupon = Coupon{Code: code, Currency: result.CouponCurrency,
+			Amount: result.CouponAmount, TransId: result.TransId,
+			Created: now, Status: Received, Changed: now}
+		l.Coupons = append(l.Coupons, coupon)
+	}
+	return coupon, l.Save()
+}
+
+func (l *Ledger) find(code string) int {
+	for i, coupon := range l.Coupons {
+		if coupon.Code == code {
+			return i
+		}
+	}
+	return -1
+}
+
+// Sync matches outstanding coupons to their creation transactions in
+// TransHistory: the transaction stays pending while the coupon isn't
+// redeemed, and becomes successful when it is (or cancelled when
+// it's redeemed by its creator). Coupons with a changed status are
+// returned, and the ledger is saved if there are any.
+func (l *Ledger) Sync(c *btce.Client) ([]Coupon, error) {
+	pending := map[uint64]int{}
+	var from, end uint64
+	for i, coupon := range l.Coupons {
+		if coupon.Status != Outstanding {
+			continue
+		}
+		pending[coupon.TransId] = i
+		if from == 0 || coupon.TransId < from {
+			from = coupon.TransId
+		}
+		if coupon.TransId > end {
+			end = coupon.TransId
+		}
+	}
+	changed := []Coupon{}
+	for len(pending) > 0 {
+		result := btce.TransHistoryResult{}
+		p := btce.TransHistoryParameters{FromId: from, EndId: end,
+			Count: PageSize, Order: "ASC"}
+		if err := c.Call(p, &result); err != nil {
+			return changed, err
+		}
+		for id, item := range result {
+			if id >= from {
+				from = id + 1
+			}
+			i, ok := pending[id]
+			if !ok {
+				continue
+			}
+			status := ""
+			switch item.Status {
+			case 2:
+				status = Redeemed
+			case 0:
+				status = Cancelled
+			}
+			if status != "" {
+				l.Coupons[i].Status, l.Coupons[i].Changed = status, l.now()
+				changed = append(changed, l.Coupons[i])
+			}
+			delete(pending, id)
+		}
+		if uint(len(result)) < PageSize || from > end {
+			break
+		}
+	}
+	if len(changed) > 0 {
+		return changed, l.Save()
+	}
+	return changed, nil
+}
+
+// Select returns coupons with a status (all if empty), oldest first.
+func (l *Ledger) Select(status string) []Coupon {
+	result := []Coupon{}
+	for _, coupon := range l.Coupons {
+		if status == "" || coupon.Status == status {
+			result = append(result, coupon)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	return result
+}
+
+// WriteCSV exports coupons as CSV with a header line.
+func WriteCSV(w io.Writer, coupons []Coupon) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"created", "status", "currency", "amount",
+		"receiver", "trans_id", "code", "changed"})
+	for _, coupon := range coupons {
+		cw.Write([]string{coupon.Created.UTC().Format(time.RFC3339),
+			coupon.Status, coupon.Currency, fmt.Sprint(coupon.Amount),
+			coupon.Receiver, fmt.Sprint(coupon.TransId), coupon.Code,
+			coupon.Changed.UTC().Format(time.RFC3339)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,139 @@
+package coupon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+func TestLedger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coupon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	btce.ScryptN = 1 << 4
+	defer func() { btce.ScryptN = 1 << 15 }()
+
+	history := btce.TransHistoryResult{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/3/info") {
+			fmt.Fprint(w, `{"pairs":{}}`)
+			return
+		}
+		r.ParseForm()
+		var result interface{}
+		switch r.Form.Get("method") {
+		case "CreateCoupon":
+			id := uint64(len(history) + 10)
+			history[id] = btce.TransHistoryItem{Type: "5", Status: 1}
+			result = btce.CreateCouponResult{Coupon: fmt.Sprint("BTCE-USD-", id), TransId: id}
+		case "RedeemCoupon":
+			result = btce.RedeemCouponResult{CouponAmount: 3, CouponCurrency: "USD", TransId: 99}
+		case "TransHistory":
+			result = history
+		}
+		data, _ := json.Marshal(result)
+		fmt.Fprintf(w, `{"success":1,"return":%s}`, data)
+	}))
+	defer server.Close()
+	c := &btce.Client{URL: server.URL}
+
+	file := filepath.Join(dir, "coupons")
+	l, err := Open(file, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := l.Create(c, btce.CreateCouponParameters{Currency: "USD", Amount: 1, Receiver: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Create(c, btce.CreateCouponParameters{Currency: "USD", Amount: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Redeem(c, "BTCE-USD-OTHER"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(file, []byte("wrong")); err != btce.ErrPassphrase {
+		t.Error("expected ErrPassphrase, got", err)
+	}
+
+	history[first.TransId] = btce.TransHistoryItem{Type: "5", Status: 2}
+	l, _ = Open(file, []byte("pass"))
+	changed, err := l.Sync(c)
+	if err != nil || len(changed) != 1 || changed[0].Code != first.Code || changed[0].Status != Redeemed {
+		t.Fatalf("%+v %v", changed, err)
+	}
+	l, _ = Open(file, []byte("pass"))
+	if n := len(l.Select(Outstanding)); n != 1 {
+		t.Error("outstanding:", n)
+	}
+	if received := l.Select(Received); len(received) != 1 || received[0].Amount != 3 {
+		t.Errorf("received: %+v", received)
+	}
+	out := &strings.Builder{}
+	WriteCSV(out, l.Select(""))
+	if lines := strings.Count(out.String(), "\n"); lines != 4 {
+		t.Error("CSV lines:", lines, out)
+	}
+}
+
+// Ledgers saved by concurrent processes keep each other's coupons
+func TestConcurrentSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coupon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	btce.ScryptN = 1 << 4
+	defer func() { btce.ScryptN = 1 << 15 }()
+
+	file := filepath.Join(dir, "coupons")
+	a, err := Open(file, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(file, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	a.Coupons = append(a.Coupons, Coupon{Code: "A", Status: Outstanding, Changed: now})
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b.Coupons = append(b.Coupons, Coupon{Code: "B", Status: Outstanding, Changed: now})
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+	a.Coupons[0].Status, a.Coupons[0].Changed = Redeemed, now.Add(time.Second)
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(file, []byte("pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Coupons) != 2 || l.Select(Redeemed)[0].Code != "A" {
+		t.Fatalf("%+v", l.Coupons)
+	}
+
+	// a save waits for the lock
+	LockTimeout = 100 * time.Millisecond
+	defer func() { LockTimeout = 10 * time.Second }()
+	if err := ioutil.WriteFile(file+".lock", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Error("saved a locked ledger:", err)
+	}
+}
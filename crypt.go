@@ -0,0 +1,94 @@
+package btce
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Scrypt cost parameters for new encrypted data (N must be a power
+// of two). Data encrypted earlier keeps its own parameters.
+var ScryptN, ScryptR, ScryptP = 1 << 15, 8, 1
+
+// encrypted is the JSON envelope of passphrase-encrypted data:
+// AES-256-GCM with a key derived by scrypt.
+type encrypted struct {
+	KDF   string `json:"kdf"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// ErrPassphrase is returned when encrypted data can't be decrypted
+// with a passphrase (a wrong one, or the data is damaged).
+var ErrPassphrase = errors.New("Wrong passphrase or damaged data")
+
+func (e *encrypted) aead(passphrase []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, e.Salt, e.N, e.R, e.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts plaintext with a passphrase, returning a JSON
+// document which can be stored as is.
+func Encrypt(plaintext, passphrase []byte) ([]byte, error) {
+	e := &encrypted{KDF: "scrypt", N: ScryptN, R: ScryptR, P: ScryptP,
+		Salt: make([]byte, 16)}
+	if _, err := rand.Read(e.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	e.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return nil, err
+	}
+	e.Data = aead.Seal(nil, e.Nonce, plaintext, nil)
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// Decrypt decrypts data produced by Encrypt.
+func Decrypt(data, passphrase []byte) ([]byte, error) {
+	e := &encrypted{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	if e.KDF != "scrypt" {
+		return nil, errors.New("Unsupported key derivation: " + e.KDF)
+	}
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.Nonce) != aead.NonceSize() {
+		return nil, ErrPassphrase
+	}
+	plaintext, err := aead.Open(nil, e.Nonce, e.Data, nil)
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted tells whether data looks like a result of Encrypt.
+func IsEncrypted(data []byte) bool {
+	e := struct {
+		KDF string `json:"kdf"`
+	}{}
+	return json.Unmarshal(data, &e) == nil && e.KDF != ""
+}
@@ -0,0 +1,247 @@
+// Package deposit watches incoming deposits: it polls TransHistory
+// for deposit entries, attributes them to deposit addresses of the
+// account, and reports status changes (like pending to confirmed) as
+// events.
+package deposit
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// TransactionType is the TransHistory type of deposits.
+const TransactionType = "1"
+
+// Status is a deposit transaction status, as in TransHistory.
+type Status uint
+
+const (
+	Failed       Status = 0
+	Pending      Status = 1
+	Confirmed    Status = 2
+	NotConfirmed Status = 3
+)
+
+func (s Status) String() string {
+	switch s {
+	case Failed:
+		return "failed"
+	case Pending:
+		return "pending"
+	case Confirmed:
+		return "confirmed"
+	case NotConfirmed:
+		return "not confirmed"
+	}
+	return "unknown"
+}
+
+// Final tells whether the status won't change anymore.
+func (s Status) Final() bool {
+	return s == Failed || s == Confirmed
+}
+
+// Deposit is a deposit transaction.
+type Deposit struct {
+	Id       uint64
+	Currency string // uppercase, as in TransHistory
+	Amount   float64
+	Address  string // deposit address of the currency, if known
+	Desc     string
+	Status   Status
+	Time     time.Time
+}
+
+// Event reports a new deposit (Previous is nil) or a status change.
+type Event struct {
+	Deposit  Deposit
+	Previous *Status
+}
+
+// Watcher tracks deposits since a moment in time.
+type Watcher struct {
+	Client   *btce.Client
+	Since    time.Time     // deposits before that are ignored
+	Interval time.Duration // between polls in Run
+
+	mutex     sync.Mutex
+	deposits  map[uint64]Deposit
+	addresses map[string]string
+	lastId    uint64
+	handlers  []func(Event)
+}
+
+// PageSize is the number of transactions requested at once by Poll.
+var PageSize uint = 1000
+
+// NewWatcher creates a watcher for deposits made since a moment.
+func NewWatcher(c *btce.Client, since time.Time) *Watcher {
+	return &Watcher{Client: c, Since: since, Interval: time.Minute,
+		deposits: map[uint64]Deposit{}, addresses: map[string]string{}}
+}
+
+// OnEvent registers an event handler.
+func (w *Watcher) OnEvent(handler func(Event)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.handlers = append(w.handlers, handler)
+}
+
+// Address returns the deposit address of a currency, asking the
+// server the first time.
+func (w *Watcher) Address(currency string) (string, error) {
+	currency = strings.ToUpper(currency)
+	w.mutex.Lock()
+	address, ok := w.addresses[currency]
+	w.mutex.Unlock()
+	if ok {
+		return address, nil
+	}
+	result := btce.CoinDepositAddressResult{}
+	err := w.Client.Call(btce.CoinDepositAddressParameters{CoinName: currency}, &result)
+	if err != nil {
+		return "", err
+	}
+	w.mutex.Lock()
+	w.addresses[currency] = result.Address
+	w.mutex.Unlock()
+	return result.Address, nil
+}
+
+// Deposits returns known deposits of a currency (all if empty),
+// newest first.
+func (w *Watcher) Deposits(currency string) []Deposit {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	result := []Deposit{}
+	for _, d := range w.deposits {
+		if currency == "" || strings.EqualFold(d.Currency, currency) {
+			result = append(result, d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id > result[j].Id })
+	return result
+}
+
+// Poll checks the history for new deposits and status changes,
+// delivering events to handlers and returning them.
+//
+// The history is requested from the oldest deposit not final yet, or
+// from the last one seen, so pending deposits are rechecked until
+// they're confirmed or failed.
+func (w *Watcher) Poll() ([]Event, error) {
+	w.mutex.Lock()
+	from := w.lastId + 1
+	for id, d := range w.deposits {
+		if !d.Status.Final() && id < from {
+			from = id
+		}
+	}
+	w.mutex.Unlock()
+
+	events := []Event{}
+	for {
+		p := btce.TransHistoryParameters{FromId: from, Count: PageSize, Order: "ASC"}
+		if w.lastId == 0 && !w.Since.IsZero() {
+			p.Since = w.Since.Unix()
+		}
+		result := btce.TransHistoryResult{}
+		if err := w.Client.Call(p, &result); err != nil {
+			return events, err
+		}
+		ids := make([]uint64, 0, len(result))
+		for id := range result {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			if item := result[id]; item.Type == TransactionType {
+				if event, ok := w.update(id, item); ok {
+					events = append(events, event)
+				}
+			}
+			if id >= from {
+				from = id + 1
+			}
+		}
+		w.mutex.Lock()
+		if len(ids) > 0 && ids[len(ids)-1] > w.lastId {
+			w.lastId = ids[len(ids)-1]
+		}
+		w.mutex.Unlock()
+		if uint(len(result)) < PageSize {
+			break
+		}
+	}
+	w.mutex.Lock()
+	handlers := w.handlers
+	w.mutex.Unlock()
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+	return events, nil
+}
+
+// update records a deposit item, returning an event if it's new or
+// its status changed.
+func (w *Watcher) update(id uint64, item btce.TransHistoryItem) (Event, bool) {
+	d := Deposit{Id: id, Currency: item.Currency, Amount: item.Amount,
+		Desc: item.Desc, Status: Status(item.Status),
+		Time: time.Unix(item.Timestamp, 0)}
+	if d.Time.Before(w.Since) {
+		return Event{}, false
+	}
+	d.Address = w.addressOf(d)
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	old, known := w.deposits[id]
+	w.deposits[id] = d
+	if !known {
+		return Event{Deposit: d}, true
+	}
+	if old.Status != d.Status {
+		previous := old.Status
+		return Event{Deposit: d, Previous: &previous}, true
+	}
+	return Event{}, false
+}
+
+// addressOf finds the address a deposit came to: one of known
+// addresses mentioned in its description, or the address of its
+// currency.
+func (w *Watcher) addressOf(d Deposit) string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, address := range w.addresses {
+		if address != "" && strings.Contains(d.Desc, address) {
+			return address
+		}
+	}
+	return w.addresses[strings.ToUpper(d.Currency)]
+}
+
+// Run polls every Interval until the context is done, returning its
+// error. Poll errors are returned as well, unless onError is given
+// to handle them (and polling continues).
+func (w *Watcher) Run(ctx context.Context, onError func(error)) error {
+	for {
+		if _, err := w.Poll(); err != nil {
+			if onError == nil {
+				return err
+			}
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(w.Interval):
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package deposit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+func TestWatcher(t *testing.T) {
+	history := map[uint64]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/3/info") {
+			fmt.Fprint(w, `{"pairs":{}}`)
+			return
+		}
+		r.ParseForm()
+		switch r.Form.Get("method") {
+		case "CoinDepositAddress":
+			fmt.Fprint(w, `{"success":1,"return":{"address":"1Addr"}}`)
+		case "TransHistory":
+			items := []string{}
+			for id, item := range history {
+				if fmt.Sprint(id) >= r.Form.Get("from_id") {
+					items = append(items, fmt.Sprintf(`"%v":%v`, id, item))
+				}
+			}
+			fmt.Fprintf(w, `{"success":1,"return":{%v}}`, strings.Join(items, ","))
+		}
+	}))
+	defer server.Close()
+
+	w := NewWatcher(&btce.Client{URL: server.URL}, time.Unix(1000, 0))
+	if address, err := w.Address("btc"); err != nil || address != "1Addr" {
+		t.Fatal(address, err)
+	}
+	history[1] = `{"type":1,"amount":5,"currency":"BTC","desc":"old","status":2,"timestamp":900}`
+	history[2] = `{"type":1,"amount":1,"currency":"BTC","desc":"BTC Payment","status":1,"timestamp":1100}`
+	history[3] = `{"type":2,"amount":1,"currency":"BTC","desc":"Withdrawal","status":2,"timestamp":1200}`
+	seen := []Event{}
+	w.OnEvent(func(e Event) { seen = append(seen, e) })
+	events, err := w.Poll()
+	if err != nil || len(events) != 1 || events[0].Previous != nil ||
+		events[0].Deposit.Id != 2 || events[0].Deposit.Address != "1Addr" {
+		t.Fatalf("%+v %v", events, err)
+	}
+	if events, _ := w.Poll(); len(events) != 0 {
+		t.Errorf("no changes expected: %+v", events)
+	}
+	history[2] = strings.Replace(history[2], `"status":1`, `"status":2`, 1)
+	history[4] = `{"type":1,"amount":2,"currency":"USD","desc":"USD Payment","status":3,"timestamp":1300}`
+	events, err = w.Poll()
+	if err != nil || len(events) != 2 || *events[0].Previous != Pending ||
+		events[0].Deposit.Status != Confirmed || events[1].Deposit.Status != NotConfirmed {
+		t.Fatalf("%+v %v", events, err)
+	}
+	if len(seen) != 3 || len(w.Deposits("btc")) != 1 || len(w.Deposits("")) != 2 {
+		t.Errorf("seen %v, deposits %+v", len(seen), w.Deposits(""))
+	}
+}
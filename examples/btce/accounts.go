@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// listAccounts shows balances of each account and in total, then
+// orders of all accounts
+func listAccounts() {
+	btce.KeyPassphrase = terminalPassphrase
+	accounts, err := btce.LoadAccounts(accountsPath, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range accounts.Names() {
+		c, err := accounts.Client(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.Logger = traceLogger()
+	}
+	total, byAccount, err := accounts.Balances()
+	if err != nil {
+		log.Fatal(err)
+	}
+	balances := newTable([]column{{"account", "account"},
+		{"currency", "currency"}, {"total", "total"},
+		{"reserved", "reserved"}}).named("balances")
+	for _, name := range accounts.Names() {
+		addBalances(balances, name, byAccount[name])
+	}
+	// the empty account name marks totals
+	addBalances(balances, "", total)
+	orders, err := accounts.Orders("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"account", "account"}, {"id", "id"},
+		{"pair", "pair"}, {"type", "type"}, {"rate", "rate"},
+		{"amount", "amount"}}).named("orders")
+	for _, order := range orders {
+		t.add(order.Account, order.Id, order.Pair, order.Type, order.Rate, order.Amount)
+	}
+	printTables(balances, t)
+}
+
+// addBalances adds non-zero balances of an account, sorted by currency
+func addBalances(t *table, account string, balances map[string]btce.Balance) {
+	for _, currency := range sortedKeys(balances) {
+		if b := balances[currency]; b.Total() > 0 {
+			t.add(account, currency, b.Total(), b.Reserved)
+		}
+	}
+}
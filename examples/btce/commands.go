@@ -0,0 +1,362 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// publicClient makes a client for public API calls (no key needed)
+func publicClient() *btce.Client {
+	return &btce.Client{Logger: traceLogger()}
+}
+
+// pairArgs splits pair arguments (separated by spaces or commas)
+func pairArgs(args []string) []string {
+	pairs := []string{}
+	for _, arg := range args {
+		for _, pair := range strings.Split(arg, ",") {
+			if pair != "" {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	return pairs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedIds[V any](m map[uint64]V) []uint64 {
+	ids := make([]uint64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// parseArgs parses flags found anywhere among args, not only before
+// the first positional argument ("depth btc_usd -limit 5"), and
+// returns positional arguments
+func parseArgs(f *flag.FlagSet, args []string) []string {
+	positional := []string{}
+	for {
+		f.Parse(args)
+		if f.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, f.Arg(0))
+		args = f.Args()[1:]
+	}
+}
+
+func usageExit(f *flag.FlagSet, usage string) {
+	fmt.Fprintln(f.Output(), "Usage:", usage)
+	f.PrintDefaults()
+	os.Exit(2)
+}
+
+func showTicker(args []string) {
+	pairs := pairArgs(args)
+	if len(pairs) == 0 {
+		log.Fatal("Usage: ticker <pair>...")
+	}
+	tickers, err := publicClient().GetTicker(pairs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"pair", "pair"}, {"last", "last"},
+		{"buy", "buy"}, {"sell", "sell"}, {"high", "high"},
+		{"low", "low"}, {"avg", "avg"}, {"volume", "volume"},
+		{"updated", "updated"}})
+	for _, pair := range sortedKeys(tickers) {
+		ti := tickers[pair]
+		t.add(pair, ti.Last, ti.Buy, ti.Sell, ti.High, ti.Low, ti.Average,
+			ti.CurrentVolume, unixTime(ti.Updated))
+	}
+	t.print()
+}
+
+func showDepth(args []string) {
+	f := flag.NewFlagSet("depth parameters", flag.ExitOnError)
+	limit := f.Uint("limit", 20, "Asks and bids to show")
+	args = parseArgs(f, args)
+	if len(args) != 1 {
+		usageExit(f, "depth [-limit n] <pair>")
+	}
+	pair := args[0]
+	depths, err := publicClient().GetDepth([]string{pair}, *limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	d := depths[pair]
+	depthTable(&d, int(*limit)).print()
+}
+
+// depthTable lists up to limit asks and bids side by side; a book
+// side may be shorter than the other, or than the limit
+func depthTable(d *btce.DepthInfo, limit int) *table {
+	t := newTable([]column{{"ask_rate", "ask rate"},
+		{"ask_amount", "ask amount"}, {"bid_rate", "bid rate"},
+		{"bid_amount", "bid amount"}})
+	for i := 0; i < limit && (i < len(d.Asks) || i < len(d.Bids)); i++ {
+		row := make([]interface{}, 4)
+		if i < len(d.Asks) {
+			row[0], row[1] = d.Asks[i].Rate(), d.Asks[i].Amount()
+		}
+		if i < len(d.Bids) {
+			row[2], row[3] = d.Bids[i].Rate(), d.Bids[i].Amount()
+		}
+		t.add(row...)
+	}
+	return t
+}
+
+func showInfo(args []string) {
+	info, err := publicClient().GetPublicInfo()
+	if err != nil {
+		log.Fatal(err)
+	}
+	only := map[string]bool{}
+	for _, pair := range pairArgs(args) {
+		only[pair] = true
+	}
+	t := newTable([]column{{"pair", "pair"}, {"decimals", "decimals"},
+		{"min_price", "min price"}, {"max_price", "max price"},
+		{"min_amount", "min amount"}, {"fee_percent", "fee %"},
+		{"hidden", "hidden"}})
+	for _, pair := range sortedKeys(info.Pairs) {
+		if len(only) > 0 && !only[pair] {
+			continue
+		}
+		p := info.Pairs[pair]
+		t.add(pair, p.DecimalPlaces, p.MinPrice, p.MaxPrice, p.MinAmount, p.Fee, p.Hidden)
+	}
+	t.print()
+}
+
+func showBalance(args []string) {
+	f := flag.NewFlagSet("balance parameters", flag.ExitOnError)
+	all := f.Bool("all", false, "Show zero balances too")
+	f.Parse(args)
+	c := getClient()
+	info := btce.GetInfoResult{}
+	if err := c.Call(btce.GetInfoParameters{}, &info); err != nil {
+		log.Fatal(err)
+	}
+	orders, err := c.GetActiveOrders("")
+	if err != nil {
+		log.Fatal(err)
+	}
+	balances := btce.NewBalances()
+	balances.SetFunds(info.Funds)
+	balances.SetOrders("", orders)
+	t := newTable([]column{{"currency", "currency"},
+		{"available", "available"}, {"in_orders", "in orders"},
+		{"total", "total"}})
+	funds := balances.All()
+	for _, currency := range sortedKeys(funds) {
+		b := funds[currency]
+		if *all || b.Total() > 0 {
+			t.add(currency, b.Available, b.Reserved, b.Total())
+		}
+	}
+	t.named("funds")
+	account := newTable([]column{{"info", "info"}, {"trade", "trade"},
+		{"withdraw", "withdraw"}, {"open_orders", "open orders"},
+		{"transactions", "transactions"}}).named("account")
+	account.add(info.Rights.Info == 1, info.Rights.Trade == 1, info.Rights.Withdraw == 1,
+		info.OpenOrders, info.TransactionCount)
+	printTables(t, account)
+}
+
+func showTrades(args []string) {
+	f := flag.NewFlagSet("trades parameters", flag.ExitOnError)
+	limit := f.Uint("limit", 20, "Trades to show")
+	args = parseArgs(f, args)
+	if len(args) != 1 {
+		usageExit(f, "trades [-limit n] <pair>")
+	}
+	pair := args[0]
+	trades, err := publicClient().GetTrades([]string{pair}, *limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"time", "time"}, {"type", "type"},
+		{"price", "price"}, {"amount", "amount"}, {"tid", "tid"}})
+	for _, tr := range trades[pair] {
+		t.add(unixTime(tr.Timestamp), tr.Type, tr.Price, tr.Amount, tr.TradeId)
+	}
+	t.print()
+}
+
+// historyFlags defines common flags of history and transactions
+func historyFlags(name string) (*flag.FlagSet, *timeFlag, *timeFlag, *uint) {
+	f := flag.NewFlagSet(name, flag.ExitOnError)
+	since, until := &timeFlag{}, &timeFlag{}
+	f.Var(since, "since", "Start time: 2006-01-02, RFC 3339 or duration ago (24h)")
+	f.Var(until, "until", "End time, same formats as -since")
+	count := f.Uint("count", 100, "Records to show at most")
+	return f, since, until, count
+}
+
+func showHistory(args []string) {
+	f, since, until, count := historyFlags("history parameters")
+	pair := f.String("pair", "", "Show trades of this pair only")
+	f.Parse(args)
+	result := btce.TradeHistoryResult{}
+	err := getClient().Call(btce.TradeHistoryParameters{Pair: *pair, Count: *count,
+		Since: since.unix(), End: until.unix()}, &result)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"id", "id"}, {"time", "time"}, {"pair", "pair"},
+		{"type", "type"}, {"amount", "amount"}, {"rate", "rate"},
+		{"order", "order"}, {"mine", "mine"}})
+	for _, id := range sortedIds(result) {
+		h := result[id]
+		t.add(id, unixTime(h.Timestamp), h.Pair, h.Type, h.Amount, h.Rate,
+			h.OrderId, h.IsYourOrder == 1)
+	}
+	t.print()
+}
+
+var transactionTypes = map[string]string{
+	"1": "deposit", "2": "withdrawal", "4": "credit", "5": "debit"}
+
+var transactionStatuses = map[uint]string{
+	0: "cancelled", 1: "waiting", 2: "successful", 3: "not confirmed"}
+
+func showTransactions(args []string) {
+	f, since, until, count := historyFlags("transactions parameters")
+	f.Parse(args)
+	result := btce.TransHistoryResult{}
+	err := getClient().Call(btce.TransHistoryParameters{Count: *count,
+		Since: since.unix(), End: until.unix()}, &result)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"id", "id"}, {"time", "time"}, {"type", "type"},
+		{"currency", "currency"}, {"amount", "amount"},
+		{"status", "status"}, {"description", "description"}})
+	for _, id := range sortedIds(result) {
+		h := result[id]
+		typ, ok := transactionTypes[h.Type]
+		if !ok {
+			typ = h.Type
+		}
+		t.add(id, unixTime(h.Timestamp), typ, h.Currency, h.Amount,
+			transactionStatuses[h.Status], h.Desc)
+	}
+	t.print()
+}
+
+var orderStatuses = map[uint]string{
+	0: "active", 1: "executed", 2: "cancelled", 3: "partially cancelled"}
+
+func showOrder(args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: order <id>")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+	o, err := getClient().GetOrderInfo(id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"id", "id"}, {"created", "created"},
+		{"pair", "pair"}, {"type", "type"}, {"rate", "rate"},
+		{"start_amount", "start amount"}, {"amount", "amount"},
+		{"status", "status"}})
+	t.add(id, unixTime(o.TimestampCreated), o.Pair, o.Type, o.Rate,
+		o.StartAmount, o.Amount, orderStatuses[o.Status])
+	t.print()
+}
+
+func showDepositAddress(args []string) {
+	if len(args) != 1 {
+		log.Fatal("Usage: deposit-address <coin>")
+	}
+	coin := strings.ToUpper(args[0])
+	result := btce.CoinDepositAddressResult{}
+	err := getClient().Call(btce.CoinDepositAddressParameters{CoinName: coin}, &result)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"coin", "coin"}, {"address", "address"}})
+	t.add(coin, result.Address)
+	t.print()
+}
+
+// depthLevel is an offer in streamed depth output
+type depthLevel struct {
+	Rate   float64 `json:"rate"`
+	Amount float64 `json:"amount"`
+}
+
+// depthUpdate is a line of fastdepth output in JSON
+type depthUpdate struct {
+	Time string       `json:"time"`
+	Pair string       `json:"pair"`
+	Asks []depthLevel `json:"asks"`
+	Bids []depthLevel `json:"bids"`
+}
+
+func depthLevels(offers []btce.Offer) []depthLevel {
+	levels := make([]depthLevel, len(offers))
+	for i, o := range offers {
+		levels[i] = depthLevel{o.Rate(), o.Amount()}
+	}
+	return levels
+}
+
+var depthHeaderPrinted bool
+
+// printDepthUpdate streams depth in a machine format: a JSON object
+// per line, or CSV rows (time, pair, side, level, rate, amount) with
+// the header printed once
+func printDepthUpdate(pair string, depth *btce.DepthInfo) {
+	now := time.Now()
+	if outputFormat == "json" {
+		line, err := json.Marshal(depthUpdate{Time: now.UTC().Format(time.RFC3339Nano),
+			Pair: pair, Asks: depthLevels(depth.Asks), Bids: depthLevels(depth.Bids)})
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(line))
+		return
+	}
+	w := csv.NewWriter(os.Stdout)
+	if !depthHeaderPrinted {
+		w.Write([]string{"time", "pair", "side", "level", "rate", "amount"})
+		depthHeaderPrinted = true
+	}
+	for _, side := range []struct {
+		name   string
+		offers []btce.Offer
+	}{{"ask", depth.Asks}, {"bid", depth.Bids}} {
+		for i, o := range side.offers {
+			w.Write([]string{now.UTC().Format(time.RFC3339Nano), pair, side.name,
+				strconv.Itoa(i), cell(o.Rate(), true), cell(o.Amount(), true)})
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/akovalenko/go-btce"
+	"github.com/akovalenko/go-btce/coupon"
+)
+
+// LedgerPassphraseEnv is the environment variable with the coupon
+// ledger passphrase; it's asked on the terminal if unset
+const LedgerPassphraseEnv = "BTCE_LEDGER_PASSPHRASE"
+
+func openLedger(fileName string) *coupon.Ledger {
+	passphrase := []byte(os.Getenv(LedgerPassphraseEnv))
+	if len(passphrase) == 0 {
+		var err error
+		passphrase, err = readPassphrase("Passphrase for " + fileName + ": ")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	ledger, err := coupon.Open(fileName, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return ledger
+}
+
+// printCoupons prints coupons with the columns of coupon.WriteCSV
+func printCoupons(coupons ...coupon.Coupon) {
+	t := newTable([]column{{"created", "created"}, {"status", "status"},
+		{"currency", "currency"}, {"amount", "amount"},
+		{"receiver", "receiver"}, {"trans_id", "trans id"},
+		{"code", "code"}, {"changed", "changed"}})
+	for _, c := range coupons {
+		t.add(c.Created, c.Status, strings.ToUpper(c.Currency), c.Amount,
+			c.Receiver, c.TransId, c.Code, c.Changed)
+	}
+	t.print()
+}
+
+// couponCommand creates, redeems or lists coupons
+func couponCommand(args []string) {
+	f := flag.NewFlagSet("coupon parameters", flag.ExitOnError)
+	ledgerFile := f.String("ledger", "coupons.ledger", "Encrypted coupon ledger")
+	policyFile := f.String("policy", "withdraw-policy.json",
+		"Withdrawal policy for coupon creation")
+	status := f.String("status", "", "List coupons with this status only")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), `Usage: coupon [flags] create <currency> <amount> [receiver]
+       coupon [flags] redeem <code>
+       coupon [flags] list -- after checking the history for redeemed coupons`)
+		f.PrintDefaults()
+	}
+	// flags may also follow the subcommand and its arguments
+	args = parseArgs(f, args)
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	switch arg(0) {
+	case "create":
+		if len(args) < 3 || len(args) > 4 {
+			f.Usage()
+			os.Exit(2)
+		}
+		amount, err := strconv.ParseFloat(arg(2), 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+		policy := loadPolicy(*policyFile)
+		ledger := openLedger(*ledgerFile)
+		c := getClient()
+		policy.Attach(c)
+		created, err := ledger.Create(c, btce.CreateCouponParameters{
+			Currency: strings.ToUpper(arg(1)), Amount: amount, Receiver: arg(3)})
+		if created.Code != "" {
+			printCoupons(created)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "redeem":
+		if len(args) != 2 {
+			f.Usage()
+			os.Exit(2)
+		}
+		ledger := openLedger(*ledgerFile)
+		redeemed, err := ledger.Redeem(getClient(), arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		printCoupons(redeemed)
+	case "list":
+		if len(args) != 1 {
+			f.Usage()
+			os.Exit(2)
+		}
+		ledger := openLedger(*ledgerFile)
+		changed, err := ledger.Sync(getClient())
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, c := range changed {
+			log.Println("Coupon", c.Code, "is", c.Status)
+		}
+		printCoupons(ledger.Select(*status)...)
+	default:
+		f.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/akovalenko/go-btce/deposit"
+)
+
+// listDeposits lists recent deposits per currency, then optionally
+// keeps watching them
+func listDeposits(args []string) {
+	f := flag.NewFlagSet("deposit parameters", flag.ExitOnError)
+	currency := f.String("currency", "", "Show deposits of this currency only")
+	since := f.Duration("since", 30*24*time.Hour, "Show deposits this recent")
+	watch := f.Bool("watch", false, "Keep watching for new deposits and status changes")
+	interval := f.Duration("interval", time.Minute, "Polling interval for -watch")
+	f.Parse(args)
+
+	w := deposit.NewWatcher(getClient(), time.Now().Add(-*since))
+	w.Interval = *interval
+	if *currency != "" {
+		if _, err := w.Address(*currency); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if _, err := w.Poll(); err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"id", "id"}, {"time", "time"},
+		{"currency", "currency"}, {"amount", "amount"},
+		{"status", "status"}, {"address", "address"},
+		{"description", "description"}})
+	for _, d := range w.Deposits(*currency) {
+		address := d.Address
+		if address == "" {
+			address, _ = w.Address(d.Currency)
+		}
+		t.add(d.Id, d.Time, d.Currency, d.Amount, d.Status.String(), address, d.Desc)
+	}
+	t.print()
+	if !*watch {
+		return
+	}
+	events := newTable([]column{{"id", "id"}, {"time", "time"},
+		{"currency", "currency"}, {"amount", "amount"}, {"status", "status"},
+		{"previous_status", "previous status"}})
+	w.OnEvent(func(e deposit.Event) {
+		d := e.Deposit
+		if *currency != "" && !strings.EqualFold(d.Currency, *currency) {
+			return
+		}
+		previous := ""
+		if e.Previous != nil {
+			previous = e.Previous.String()
+		}
+		events.stream(d.Id, d.Time, d.Currency, d.Amount, d.Status.String(), previous)
+	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := w.Run(ctx, func(err error) { log.Println("Polling failed:", err) })
+	if err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// readPassphrase prompts for a passphrase on the terminal, with echo
+// turned off by stty.
+func readPassphrase(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+	stty := func(args ...string) error {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = tty
+		return cmd.Run()
+	}
+	if err := stty("-echo"); err == nil {
+		defer stty("echo")
+	}
+	fmt.Fprint(tty, prompt)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+// terminalPassphrase is btce.KeyPassphrase falling back to a prompt
+func terminalPassphrase(fileName string) ([]byte, error) {
+	if passphrase, ok := os.LookupEnv(btce.KeyPassphraseEnv); ok {
+		return []byte(passphrase), nil
+	}
+	return readPassphrase("Passphrase for " + fileName + ": ")
+}
+
+// newPassphrase reads a new passphrase (from the environment, or
+// twice from the terminal)
+func newPassphrase() []byte {
+	if passphrase, ok := os.LookupEnv(btce.KeyPassphraseEnv); ok {
+		return []byte(passphrase)
+	}
+	first, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	again, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(first) == 0 || !bytes.Equal(first, again) {
+		log.Fatal("Passphrases are empty or don't match")
+	}
+	return first
+}
+
+// keyCommand encrypts or decrypts a key file
+func keyCommand(args []string) {
+	f := flag.NewFlagSet("key parameters", flag.ExitOnError)
+	in := f.String("in", keyFile, "Key file to read")
+	out := f.String("out", "", "Key file to write (default: replace -in)")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: key [-in file] [-out file] encrypt|decrypt")
+		f.PrintDefaults()
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		// allow "key encrypt -in ..." as well
+		args = append(append([]string{}, args[1:]...), args[0])
+	}
+	f.Parse(args)
+	if *out == "" {
+		*out = *in
+	}
+	var err error
+	switch f.Arg(0) {
+	case "encrypt":
+		err = btce.EncryptKey(*in, *out, newPassphrase())
+	case "decrypt":
+		var passphrase []byte
+		if passphrase, err = terminalPassphrase(*in); err == nil {
+			err = btce.DecryptKey(*in, *out, passphrase)
+		}
+	default:
+		f.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Written", *out)
+}
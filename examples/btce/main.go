@@ -7,131 +7,129 @@ import (
 	"flag"
 	"fmt"
 	"github.com/akovalenko/go-btce"
-	"sort"
 	"log"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
-type whichOrders struct {
-	id        uint64
-	pair      string
-	minRate   float64
-	maxRate   float64
-	minAmount float64
-	maxAmount float64
-}
-
-func (filter whichOrders) satisfies(id uint64, order btce.ActiveOrder) bool {
-	if filter.id != 0 && filter.id != id {
-		return false
-	}
-	if filter.pair != "" && filter.pair != order.Pair {
-		return false
-	}
-	if filter.minRate != 0 && filter.minRate > order.Rate {
-		return false
-	}
-	if filter.maxRate != 0 && filter.maxRate < order.Rate {
-		return false
-	}
-	if filter.minAmount != 0 && filter.minAmount > order.Amount {
-		return false
-	}
-	if filter.maxAmount != 0 && filter.maxAmount < order.Amount {
-		return false
-	}
-	return true
-}
-
-func (filter whichOrders) matchActiveOrders(set btce.ActiveOrdersResult) []uint64 {
-	result := []uint64{}
-	for id, description := range set {
-		if filter.satisfies(id, description) {
-			result = append(result, id)
-		}
-	}
-	return result
-}
-
-func (filter *whichOrders) flagSet() *flag.FlagSet {
+// orderFilterFlags defines order filtering flags
+func orderFilterFlags(filter *btce.OrderFilter) *flag.FlagSet {
 	f := flag.NewFlagSet("order filtering parameters", flag.ExitOnError)
-	f.Uint64Var(&filter.id, "id", 0, "Order identifier (0 = no filter)")
-	f.StringVar(&filter.pair, "pair", "", "Currency pair")
-	f.Float64Var(&filter.minRate, "min-rate", 0, "Minimum rate")
-	f.Float64Var(&filter.maxRate, "max-rate", 0, "Maximum rate (0 = no limit)")
-	f.Float64Var(&filter.minAmount, "min-amount", 0, "Minimum amount")
-	f.Float64Var(&filter.maxAmount, "max-amount", 0, "Maximum amount (0 = no limit)")
+	f.Uint64Var(&filter.Id, "id", 0, "Order identifier (0 = no filter)")
+	f.StringVar(&filter.Pair, "pair", "", "Currency pair")
+	f.StringVar(&filter.Type, "type", "", "Order type (buy or sell)")
+	f.Float64Var(&filter.MinRate, "min-rate", 0, "Minimum rate")
+	f.Float64Var(&filter.MaxRate, "max-rate", 0, "Maximum rate (0 = no limit)")
+	f.Float64Var(&filter.MinAmount, "min-amount", 0, "Minimum amount")
+	f.Float64Var(&filter.MaxAmount, "max-amount", 0, "Maximum amount (0 = no limit)")
 	return f
 }
 
 var keyFile string
+var accountsPath string
+var account string
+var traceRpc bool
+var readOnly bool
 
 func init() {
-	flag.StringVar(&keyFile, "key", "key.json", `API token JSON file; "{key:.. secret:..}"`)
+	flag.StringVar(&keyFile, "key", "key.json",
+		`API token JSON file "{key:.. secret:..}" (maybe encrypted), env[:PREFIX_] or cmd:COMMAND`)
+	flag.StringVar(&accountsPath, "accounts", "accounts",
+		"Directory of account key files, or accounts config file")
+	flag.StringVar(&account, "account", "", "Use a named account instead of -key")
+	flag.BoolVar(&traceRpc, "traceRpc", false, "Trace BTC-e RPC calls")
+	flag.BoolVar(&readOnly, "read-only", false, "Refuse any calls changing orders or funds")
+	flag.StringVar(&outputFormat, "o", "table", "Output format: table, json or csv")
 }
 
-// printFunds prints available funds (returned from private getInfo
-// and trade operations)
-func printFunds(m map[string]float64) {
-	keys := make([]string, len(m))
-	i := 0
-	for key := range m {
-		keys[i] = key
-		i++
-	}
-	sort.Strings(keys)
-	fmt.Println("Available funds:")
-	for _,key := range keys {
-		if m[key]>0 {
-			fmt.Printf("%v %.8f\n", key, m[key])
-		}
+// traceLogger returns a debug logger to stderr with -traceRpc, nil
+// otherwise
+func traceLogger() *slog.Logger {
+	if !traceRpc {
+		return nil
 	}
+	return slog.New(slog.NewTextHandler(os.Stderr,
+		&slog.HandlerOptions{Level: slog.LevelDebug}))
 }
 
-// getClient initializes BTC-e client with -key
+// getClient initializes BTC-e client with -key, or -account from -accounts
 func getClient() *btce.Client {
-	c := &btce.Client{}
-	err := c.ReadKey(keyFile)
+	c := &btce.Client{Logger: traceLogger()}
+	btce.KeyPassphrase = terminalPassphrase
+	if account != "" {
+		accounts, err := btce.LoadAccounts(accountsPath, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		c, err = accounts.Client(account)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.Logger = traceLogger()
+		c.ReadOnly = readOnly
+		return c
+	}
+	err := c.LoadKey(keyFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	c.ReadOnly = readOnly
 	return c
 }
 
 // listOrders lists orders matching criteria
-func listOrders(w whichOrders) {
+func listOrders(filter btce.OrderFilter) {
 	c := getClient()
-	orders := c.ActiveOrders(btce.ActiveOrdersParameters{Pair: w.pair})
-	selected := w.matchActiveOrders(orders)
-	for _, id := range selected {
+	orders := c.ActiveOrders(btce.ActiveOrdersParameters{Pair: filter.Pair})
+	t := newTable([]column{{"id", "id"}, {"created", "created"},
+		{"pair", "pair"}, {"type", "type"}, {"rate", "rate"},
+		{"amount", "amount"}, {"status", "status"}})
+	for _, id := range filter.Select(orders) {
 		order := orders[id]
-		fmt.Println("Order #", id, order.Pair, order.Type,
-			"rate:", order.Rate, "amount:", order.Amount,
-			"status: ", order.Status)
+		t.add(id, unixTime(order.TimestampCreated), order.Pair, order.Type,
+			order.Rate, order.Amount, orderStatuses[order.Status])
 	}
+	t.print()
 }
 
-func cancelOrders(w whichOrders) {
+// cancelOrders cancels orders matching criteria, going on after
+// failures, and reports what was done
+func cancelOrders(filter btce.OrderFilter, dryRun bool) {
 	c := getClient()
-	orders := c.ActiveOrders(btce.ActiveOrdersParameters{Pair: w.pair})
-	selected := w.matchActiveOrders(orders)
+	results, err := c.CancelAll(filter, dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
 	var funds map[string]float64
-	for _, id := range selected {
-		order := orders[id]
-		fmt.Println("Cancelling order #", id, order.Pair, order.Type,
-			"rate:", order.Rate, "amount:", order.Amount)
-		r := c.CancelOrder(btce.CancelOrderParameters{OrderId: id})
-		funds = r.Funds
+	failed := 0
+	t := newTable([]column{{"id", "id"}, {"pair", "pair"}, {"type", "type"},
+		{"rate", "rate"}, {"amount", "amount"}, {"result", "result"},
+		{"error", "error"}}).named("orders")
+	for _, r := range results {
+		result, errorText := "cancelled", ""
+		if dryRun {
+			result = "would cancel"
+		}
+		if r.Err != nil {
+			result, errorText = "failed", r.Err.Error()
+			failed++
+		} else if r.Result.Funds != nil {
+			funds = r.Result.Funds
+		}
+		t.add(r.OrderId, r.Order.Pair, r.Order.Type, r.Order.Rate, r.Order.Amount,
+			result, errorText)
 	}
-	if funds != nil {
-		printFunds(funds)
+	printTables(t, fundsTable(funds))
+	log.Printf("%v orders matched, %v failed", len(results), failed)
+	if failed > 0 {
+		os.Exit(1)
 	}
 }
 
-func placeOrder(t string, amount string, pair string, rate string) {
+func placeOrder(t string, amount string, pair string, rate string, slippage float64) {
 	c := getClient()
 	info := c.PublicInfo()
 	_, foundPair := info.Pairs[pair]
@@ -151,36 +149,47 @@ func placeOrder(t string, amount string, pair string, rate string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	r := 0e0
+	var tr btce.TradeResult
+	market := newTable([]column{{"type", "type"}, {"rate", "rate"},
+		{"expected_price", "expected price"},
+		{"actual_price", "actual price"}}).named("market")
 	if rate != "" {
-		r, err = strconv.ParseFloat(rate, 64)
+		r, err := strconv.ParseFloat(rate, 64)
 		if err != nil {
 			log.Fatal(err)
 		}
+		tr = c.Trade(btce.TradeParameters{Pair: pair, Type: t, Amount: a, Rate: r})
 	} else {
-		if t != "sell" {
-			log.Fatal("Buy on market rate not supported -- sell only")
+		var mr *btce.MarketResult
+		if t == "buy" {
+			mr, err = c.MarketBuy(pair, a, slippage)
+		} else {
+			mr, err = c.MarketSell(pair, a, slippage)
 		}
-		r = info.Pairs[pair].MinAmount
-	}
-	tr := c.Trade(btce.TradeParameters{Pair: pair, Type: t, Amount: a, Rate: r})
-	if tr.OrderId == 0 {
-		fmt.Println("Order fully executed.")
-	} else {
-		fmt.Printf("New order #%v, received %.8f, remains %.8f\n",
-			tr.OrderId, tr.Received, tr.Remains)
+		if err != nil {
+			log.Fatal(err)
+		}
+		market.add(t, mr.Rate, mr.ExpectedPrice, mr.ActualPrice)
+		tr = mr.Trade
 	}
-	printFunds(tr.Funds)
+	// order_id is 0 when the order is fully executed
+	trade := newTable([]column{{"order_id", "order id"},
+		{"received", "received"}, {"remains", "remains"}}).named("trade")
+	trade.add(tr.OrderId, tr.Received, tr.Remains)
+	printTables(trade, market, fundsTable(tr.Funds))
 }
 
 func monitorDepth(pair string) {
 	ptr := btce.FastDepth(pair)
+	if ptr == nil {
+		log.Fatal("No depth for ", pair)
+	}
 	for {
-		fmt.Println("Depth for ",pair)
-		for i:=0; i<20; i++ {
-			fmt.Printf("ask: %.8f %.8f  bid: %.8f %.8f\n",
-				ptr.Asks[i].Rate(),ptr.Asks[i].Amount(),
-				ptr.Bids[i].Rate(),ptr.Bids[i].Amount())
+		if outputFormat != "table" {
+			printDepthUpdate(pair, ptr)
+		} else {
+			fmt.Println("Depth for", pair)
+			depthTable(ptr, 20).print()
 		}
 		for  {
 			time.Sleep(time.Second/2)
@@ -198,26 +207,90 @@ func monitorDepth(pair string) {
 
 func main() {
 	flag.Parse()
+	checkOutputFormat()
+	if traceRpc {
+		btce.SetPushLogger(traceLogger())
+	}
 	switch flag.Arg(0) {
 	case "orders":
-		w := whichOrders{}
-		w.flagSet().Parse(flag.Args()[1:])
-		listOrders(w)
+		filter := btce.OrderFilter{}
+		orderFilterFlags(&filter).Parse(flag.Args()[1:])
+		listOrders(filter)
 	case "cancel":
-		w := whichOrders{}
-		w.flagSet().Parse(flag.Args()[1:])
-		cancelOrders(w)
+		filter := btce.OrderFilter{}
+		f := orderFilterFlags(&filter)
+		dryRun := f.Bool("dry-run", false, "Only show what would be cancelled")
+		f.Parse(flag.Args()[1:])
+		cancelOrders(filter, *dryRun)
 	case "place":
-		placeOrder(flag.Arg(1), flag.Arg(2), flag.Arg(3), flag.Arg(4))
+		f := flag.NewFlagSet("order placement parameters", flag.ExitOnError)
+		slippage := f.Float64("slippage", 0.01,
+			"Maximum slippage for market orders (0.01 = 1%)")
+		f.Parse(flag.Args()[1:])
+		placeOrder(f.Arg(0), f.Arg(1), f.Arg(2), f.Arg(3), *slippage)
 	case "fastdepth":
 		monitorDepth(flag.Arg(1))
+	case "tui":
+		runTUI(flag.Args()[1:])
+	case "record":
+		recordMarket(flag.Args()[1:])
+	case "replay":
+		replayDepth(flag.Args()[1:])
+	case "key":
+		keyCommand(flag.Args()[1:])
+	case "accounts":
+		listAccounts()
+	case "withdraw":
+		withdraw(flag.Args()[1:])
+	case "coupon":
+		couponCommand(flag.Args()[1:])
+	case "deposits":
+		listDeposits(flag.Args()[1:])
+	case "ticker":
+		showTicker(flag.Args()[1:])
+	case "depth":
+		showDepth(flag.Args()[1:])
+	case "info":
+		showInfo(flag.Args()[1:])
+	case "balance":
+		showBalance(flag.Args()[1:])
+	case "trades":
+		showTrades(flag.Args()[1:])
+	case "history":
+		showHistory(flag.Args()[1:])
+	case "transactions":
+		showTransactions(flag.Args()[1:])
+	case "order":
+		showOrder(flag.Args()[1:])
+	case "deposit-address":
+		showDepositAddress(flag.Args()[1:])
 	default:
-		fmt.Printf(`Usage: %v [-key key.json] subcommand
+		fmt.Printf(`Usage: %v [-key key.json | -account name] [-read-only] [-traceRpc] [-o table|json|csv] subcommand
 Subcommands:
+ ticker <pair>... -- tickers of currency pairs
+ depth [-limit 20] <pair> -- asks and bids of a pair
+ info [pair...] -- limits and fees of pairs
+ trades [-limit 20] <pair> -- recent public trades of a pair
+ balance [-all] -- funds available and in orders, key rights
+ history [-pair p] [-since t] [-until t] [-count n] -- your trades
+ transactions [-since t] [-until t] [-count n] -- deposits, withdrawals etc.
+ order <id> -- an order of yours
+ deposit-address <coin> -- address for deposits of a coin
  orders -- list orders (all or matching, try orders -h for usage)
  cancel -- cancel orders (all or matching, -h for help)
- place <sell/buy> <amount> <pair> <rate> -- place order
+ place [-slippage 0.01] <sell/buy> <amount> <pair> [rate] -- place order,
+   on market rate when rate is omitted
  fastdepth <pair> -- monitor depth instantly, update as orders change
+ tui [-amount a] [-view] <pair> -- full-screen order book, trades and
+   our orders; place or cancel orders at the cursor's price
+ record -- record market data to daily files until interrupted (-h for help)
+ replay -- show recorded depth snapshots (-h for help)
+ key encrypt|decrypt -- encrypt a key file with a passphrase, or decrypt it
+ accounts -- show balances and orders of all accounts (see -accounts)
+ withdraw [-policy file] <currency> <amount> <address> -- withdraw coins
+   to a whitelisted address, within limits, after confirmation
+ coupon create|redeem|list -- manage coupons in an encrypted ledger (-h for help)
+ deposits [-currency btc] [-watch] -- list recent deposits, watch for new ones
 `, os.Args[0])
 	}
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+	"github.com/akovalenko/go-btce/record"
+)
+
+// recordMarket records market data until interrupted
+func recordMarket(args []string) {
+	r := &record.Recorder{Client: &btce.Client{Logger: traceLogger()}}
+	f := flag.NewFlagSet("recording parameters", flag.ExitOnError)
+	pairs := f.String("pairs", "btc_usd", "Comma-separated currency pairs")
+	f.StringVar(&r.Dir, "dir", "market-data", "Directory for daily files")
+	f.DurationVar(&r.Interval, "interval", record.DefaultInterval, "Polling interval")
+	f.BoolVar(&r.Ticker, "ticker", true, "Record tickers")
+	f.BoolVar(&r.Depth, "depth", true, "Record depth")
+	f.BoolVar(&r.Trades, "trades", true, "Record trades")
+	f.BoolVar(&r.UsePush, "push", false, "Take depth from Push API")
+	f.UintVar(&r.DepthLimit, "depth-limit", 150, "Depth levels to poll")
+	f.Parse(args)
+	r.Pairs = strings.Split(*pairs, ",")
+	r.OnError = func(err error) { log.Println("Recording failed:", err) }
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Println("Recording", r.Pairs, "to", r.Dir)
+	if err := r.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+// replayDepth prints the best bid and ask of recorded depth snapshots
+func replayDepth(args []string) {
+	f := flag.NewFlagSet("replay parameters", flag.ExitOnError)
+	pair := f.String("pair", "btc_usd", "Currency pair")
+	dir := f.String("dir", "market-data", "Directory with daily files")
+	since := f.String("since", "", "First day (2006-01-02)")
+	until := f.String("until", "", "Last day (2006-01-02)")
+	f.Parse(args)
+	files := f.Args()
+	if len(files) == 0 {
+		var from, to time.Time
+		var err error
+		if *since != "" {
+			if from, err = time.Parse("2006-01-02", *since); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *until != "" {
+			if to, err = time.Parse("2006-01-02", *until); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if files, err = record.Files(*dir, from, to); err != nil {
+			log.Fatal(err)
+		}
+	}
+	snapshots, err := record.Depths(*pair, files...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"time", "time"}, {"bid_rate", "bid rate"},
+		{"bid_amount", "bid amount"}, {"ask_rate", "ask rate"},
+		{"ask_amount", "ask amount"}, {"bid_levels", "bid levels"},
+		{"ask_levels", "ask levels"}})
+	for _, s := range snapshots {
+		row := []interface{}{s.Time, nil, nil, nil, nil, len(s.Bids), len(s.Asks)}
+		if len(s.Bids) > 0 {
+			row[1], row[2] = s.Bids[0].Rate(), s.Bids[0].Amount()
+		}
+		if len(s.Asks) > 0 {
+			row[3], row[4] = s.Asks[0].Rate(), s.Asks[0].Amount()
+		}
+		t.add(row...)
+	}
+	t.print()
+}
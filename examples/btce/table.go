@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// outputFormat is set by -o: table, json or csv
+var outputFormat = "table"
+
+// column is a table column: key names it in JSON and CSV, and is
+// part of the output schema, so it must not change; label is the
+// heading in text output.
+type column struct {
+	key   string
+	label string
+}
+
+// table is command output: columns and rows of cells. In JSON, each
+// row is an object with column keys.
+type table struct {
+	name     string // key of the table when a command outputs several
+	columns  []column
+	rows     [][]interface{}
+	streamed bool // header written by stream
+}
+
+func newTable(columns []column) *table {
+	return &table{columns: columns}
+}
+
+// named sets the table name
+func (t *table) named(name string) *table {
+	t.name = name
+	return t
+}
+
+// add adds a row
+func (t *table) add(cells ...interface{}) {
+	t.rows = append(t.rows, cells)
+}
+
+// print writes the table in the output format
+func (t *table) print() {
+	printTables(t)
+}
+
+func (t *table) keys() []string {
+	keys := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		keys[i] = c.key
+	}
+	return keys
+}
+
+// cell formats a cell for a table (human) or CSV (machine)
+func cell(v interface{}, machine bool) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		if machine {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return fmt.Sprintf("%.8g", v)
+	case time.Time:
+		if machine {
+			return v.UTC().Format(time.RFC3339)
+		}
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// jsonValue is a cell as JSON value: times in RFC 3339, numbers,
+// strings, booleans and nil (null) as they are
+func jsonValue(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+// object converts a row to a JSON object
+func (t *table) object(row []interface{}) map[string]interface{} {
+	object := map[string]interface{}{}
+	for i, v := range row {
+		object[t.columns[i].key] = jsonValue(v)
+	}
+	return object
+}
+
+// objects converts rows to JSON objects
+func (t *table) objects() []map[string]interface{} {
+	objects := make([]map[string]interface{}, len(t.rows))
+	for i, row := range t.rows {
+		objects[i] = t.object(row)
+	}
+	return objects
+}
+
+// labels returns column headings for text output
+func (t *table) labels() []string {
+	labels := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		labels[i] = strings.ToUpper(c.label)
+	}
+	return labels
+}
+
+// stream writes a row at once, for output that goes on as events
+// happen: a JSON object per line, or a CSV or text row, with the
+// header written before the first row only
+func (t *table) stream(cells ...interface{}) {
+	switch outputFormat {
+	case "json":
+		line, err := json.Marshal(t.object(cells))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(line))
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		if !t.streamed {
+			w.Write(t.keys())
+		}
+		record := make([]string, len(cells))
+		for i, v := range cells {
+			record[i] = cell(v, true)
+		}
+		w.Write(record)
+		w.Flush()
+	default:
+		// rows can't be aligned with later ones: columns are wide
+		w := tabwriter.NewWriter(os.Stdout, 12, 0, 2, ' ', 0)
+		if !t.streamed {
+			fmt.Fprintln(w, strings.Join(t.labels(), "\t"))
+		}
+		text := make([]string, len(cells))
+		for i, v := range cells {
+			text[i] = cell(v, false)
+		}
+		fmt.Fprintln(w, strings.Join(text, "\t"))
+		w.Flush()
+	}
+	t.streamed = true
+}
+
+// printTables writes output of a command: in JSON, one table is an
+// array of objects, several tables make an object of named arrays;
+// in CSV and as text, tables are separated by empty lines.
+func printTables(tables ...*table) {
+	switch outputFormat {
+	case "json":
+		var v interface{}
+		if len(tables) == 1 {
+			v = tables[0].objects()
+		} else {
+			named := map[string]interface{}{}
+			for _, t := range tables {
+				named[t.name] = t.objects()
+			}
+			v = named
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(v); err != nil {
+			log.Fatal(err)
+		}
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		for i, t := range tables {
+			if i > 0 {
+				w.Flush()
+				fmt.Println()
+			}
+			w.Write(t.keys())
+			for _, row := range t.rows {
+				record := make([]string, len(row))
+				for j, v := range row {
+					record[j] = cell(v, true)
+				}
+				w.Write(record)
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		for i, t := range tables {
+			if i > 0 {
+				fmt.Println()
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, strings.Join(t.labels(), "\t"))
+			for _, row := range t.rows {
+				cells := make([]string, len(row))
+				for j, v := range row {
+					cells[j] = cell(v, false)
+				}
+				fmt.Fprintln(w, strings.Join(cells, "\t"))
+			}
+			w.Flush()
+		}
+	}
+}
+
+// checkOutputFormat validates -o
+func checkOutputFormat() {
+	switch outputFormat {
+	case "table", "json", "csv":
+	default:
+		log.Fatal("Output format (-o) must be table, json or csv, not ", outputFormat)
+	}
+}
+
+// fundsTable lists non-zero funds, sorted by currency
+func fundsTable(funds map[string]float64) *table {
+	t := newTable([]column{{"currency", "currency"}, {"amount", "amount"}}).named("funds")
+	for _, currency := range sortedKeys(funds) {
+		if funds[currency] > 0 {
+			t.add(currency, funds[currency])
+		}
+	}
+	return t
+}
+
+// unixTime converts API timestamps
+func unixTime(timestamp int64) time.Time {
+	return time.Unix(timestamp, 0)
+}
+
+// parseTime accepts a date (2006-01-02), RFC 3339 time or a duration
+// meaning "that long ago"
+func parseTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// timeFlag is a flag.Value for parseTime
+type timeFlag struct{ time.Time }
+
+func (t *timeFlag) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(s string) (err error) {
+	t.Time, err = parseTime(s)
+	return err
+}
+
+// unix returns a Unix timestamp, 0 for zero time
+func (t *timeFlag) unix() int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// output returns what f writes to stdout in a format
+func output(t *testing.T, format string, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, saved := os.Stdout, outputFormat
+	os.Stdout, outputFormat = w, format
+	defer func() { os.Stdout, outputFormat = stdout, saved }()
+	f()
+	w.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStream(t *testing.T) {
+	at := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	for format, expected := range map[string]string{
+		"json": `{"amount":0.5,"fee_percent":0.2,"time":"2017-06-01T12:00:00Z"}` + "\n" +
+			`{"amount":1,"fee_percent":null,"time":"2017-06-01T12:00:00Z"}` + "\n",
+		"csv": "time,amount,fee_percent\n2017-06-01T12:00:00Z,0.5,0.2\n" +
+			"2017-06-01T12:00:00Z,1,\n",
+	} {
+		events := newTable([]column{{"time", "time"}, {"amount", "amount"},
+			{"fee_percent", "fee %"}})
+		out := output(t, format, func() {
+			events.stream(at, 0.5, 0.2)
+			events.stream(at, 1.0, nil)
+		})
+		if out != expected {
+			t.Errorf("%v: %q, expected %q", format, out, expected)
+		}
+	}
+}
+
+// A book side shorter than the limit (fastdepth used to panic)
+func TestDepthTableShortSide(t *testing.T) {
+	d := &btce.DepthInfo{Asks: []btce.Offer{{101, 1}, {102, 2}, {103, 3}},
+		Bids: []btce.Offer{{100, 0.5}}}
+	table := depthTable(d, 20)
+	if len(table.rows) != 3 {
+		t.Fatal("rows:", table.rows)
+	}
+	if last := table.rows[2]; last[0] != 103.0 || last[2] != nil || last[3] != nil {
+		t.Error("last row:", last)
+	}
+	if rows := depthTable(d, 2).rows; len(rows) != 2 {
+		t.Error("rows over the limit:", rows)
+	}
+	out := output(t, "table", func() { table.print() })
+	expected := "ASK RATE  ASK AMOUNT  BID RATE  BID AMOUNT\n" +
+		"101       1           100       0.5\n" +
+		"102       2                     \n" +
+		"103       3                     \n"
+	if out != expected {
+		t.Errorf("%q", out)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ANSI escapes used by the TUI
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiReverse = "\x1b[7m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+)
+
+// terminal is /dev/tty in raw mode (set by stty), on the alternate
+// screen
+type terminal struct {
+	tty   *os.File
+	saved string // stty settings to restore
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	t := &terminal{tty: tty}
+	saved, err := t.stty("-g")
+	if err == nil {
+		_, err = t.stty("raw", "-echo")
+	}
+	if err != nil {
+		tty.Close()
+		return nil, fmt.Errorf("Can't set terminal mode: %v", err)
+	}
+	t.saved = strings.TrimSpace(saved)
+	// alternate screen, cursor hidden
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l")
+	return t, nil
+}
+
+func (t *terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// close restores the screen and terminal settings; it may be called
+// from any goroutine, more than once
+func (t *terminal) close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	fmt.Fprint(t.tty, ansiReset+"\x1b[?25h\x1b[?1049l")
+	t.stty(t.saved)
+	t.tty.Close()
+}
+
+// size returns rows and columns of the terminal, 24x80 if unknown
+func (t *terminal) size() (rows, cols int) {
+	out, err := t.stty("size")
+	if err == nil {
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return rows, cols
+		}
+	}
+	return 24, 80
+}
+
+// draw replaces the screen with lines
+func (t *terminal) draw(lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString(ansiReset + "\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.closed {
+		t.tty.WriteString(b.String())
+	}
+}
+
+// Keys sent by readKeys besides characters
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdown"
+	keyEnter     = "enter"
+	keyBack      = "backspace"
+	keyEscape    = "esc"
+	keyInterrupt = "ctrl-c"
+)
+
+// readKeys sends keys pressed until reading fails, then closes keys.
+// An escape sequence is expected to come in a single read.
+func (t *terminal) readKeys(keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := t.tty.Read(buf)
+		if err != nil {
+			return
+		}
+		for input := string(buf[:n]); input != ""; {
+			key, size := parseKey(input)
+			if key != "" {
+				keys <- key
+			}
+			input = input[size:]
+		}
+	}
+}
+
+var escapeKeys = map[string]string{
+	"\x1b[A": keyUp, "\x1b[B": keyDown, "\x1bOA": keyUp, "\x1bOB": keyDown,
+	"\x1b[5~": keyPageUp, "\x1b[6~": keyPageDown}
+
+// parseKey returns the first key of input and its length
+func parseKey(input string) (string, int) {
+	for seq, key := range escapeKeys {
+		if strings.HasPrefix(input, seq) {
+			return key, len(seq)
+		}
+	}
+	switch input[0] {
+	case '\x1b':
+		// unknown sequences are skipped as a whole
+		if len(input) > 1 && (input[1] == '[' || input[1] == 'O') {
+			i := 2
+			for i < len(input) && (input[i] < 0x40 || input[i] > 0x7e) {
+				i++
+			}
+			if i < len(input) {
+				i++
+			}
+			return "", i
+		}
+		return keyEscape, 1
+	case '\r', '\n':
+		return keyEnter, 1
+	case 0x7f, '\b':
+		return keyBack, 1
+	case 3:
+		return keyInterrupt, 1
+	}
+	r, size := utf8.DecodeRuneInString(input)
+	return string(r), size
+}
+
+// fit pads or truncates s to width runes
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// bookLevel is a price level of the order book as shown by the TUI
+type bookLevel struct {
+	side   string // "ask" or "bid"
+	rate   float64
+	amount float64
+	total  float64 // cumulative amount from the best offer
+}
+
+// bookLevels returns up to n asks, highest first (so the best ask is
+// the last), and up to n bids, best first
+func bookLevels(d *btce.DepthInfo, n int) (asks, bids []bookLevel) {
+	total := 0.0
+	for i := 0; i < n && i < len(d.Asks); i++ {
+		total += d.Asks[i].Amount()
+		asks = append([]bookLevel{{"ask", d.Asks[i].Rate(), d.Asks[i].Amount(), total}}, asks...)
+	}
+	total = 0
+	for i := 0; i < n && i < len(d.Bids); i++ {
+		total += d.Bids[i].Amount()
+		bids = append(bids, bookLevel{"bid", d.Bids[i].Rate(), d.Bids[i].Amount(), total})
+	}
+	return asks, bids
+}
+
+// orderSide is the book side where an order of ours is
+func orderSide(o btce.ActiveOrder) string {
+	if o.Type == "sell" {
+		return "ask"
+	}
+	return "bid"
+}
+
+// tradeUI is a full-screen order book of a pair, with recent trades
+// and our orders, placing or cancelling orders at the cursor's price
+type tradeUI struct {
+	pair   string
+	info   btce.PairInfo
+	public *btce.Client
+	client *btce.Client // nil when only viewing
+	amount float64      // of orders to place
+
+	depth  *btce.DepthInfo
+	trades []btce.TradeInfo
+	orders btce.ActiveOrdersResult
+
+	levels     []bookLevel // as shown: asks, then bids
+	cursorSide string
+	cursorRate float64 // 0 for the best bid
+
+	message string
+	prompt  string
+	confirm func()       // called on "y" while prompting
+	done    func(string) // called with input on Enter while prompting
+	input   string
+
+	updates chan func()   // state changes from other goroutines
+	refresh chan struct{} // poll trades and orders now
+	private sync.Mutex    // held by calls of client, which share its nonce
+}
+
+// runTUI shows the order book of a pair until "q" is pressed
+func runTUI(args []string) {
+	f := flag.NewFlagSet("tui parameters", flag.ExitOnError)
+	amount := f.Float64("amount", 0, "Amount of orders to place (default: minimum for the pair)")
+	view := f.Bool("view", false, "Only view the market, without a key")
+	interval := f.Duration("interval", 5*time.Second, "Polling interval for trades and our orders")
+	f.Parse(args)
+	if f.NArg() != 1 {
+		usageExit(f, "tui [-amount a] [-view] [-interval 5s] <pair>")
+	}
+	u := &tradeUI{pair: f.Arg(0), public: publicClient(), amount: *amount,
+		cursorSide: "bid", updates: make(chan func(), 16), refresh: make(chan struct{}, 1)}
+	info, err := u.public.GetPublicInfo()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var ok bool
+	if u.info, ok = info.Pairs[u.pair]; !ok {
+		log.Fatal("Unknown pair ", u.pair)
+	}
+	if u.amount == 0 {
+		u.amount = u.info.MinAmount
+	}
+	if !*view {
+		u.client = getClient()
+	}
+	if u.depth = btce.FastDepth(u.pair); u.depth == nil {
+		log.Fatal("No depth for ", u.pair)
+	}
+	term, err := openTerminal()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer term.close()
+	// the push subsystem fails by panicking in its own goroutine,
+	// where deferred calls of this one don't run
+	btce.SetPushFailureHandler(func(error) { term.close() })
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	u.run(term, *interval, signals)
+}
+
+// run draws the UI on each change, and every 1/4 second to follow
+// the push depth stream, until quit or a signal
+func (u *tradeUI) run(term *terminal, interval time.Duration, signals <-chan os.Signal) {
+	keys := make(chan string)
+	go term.readKeys(keys)
+	go u.poll(interval)
+	ticker := time.NewTicker(time.Second / 4)
+	defer ticker.Stop()
+	rows, cols := term.size()
+	sized := time.Now()
+	for {
+		term.draw(u.render(rows, cols))
+		select {
+		case key, ok := <-keys:
+			if !ok || !u.key(key) {
+				return
+			}
+		case <-signals:
+			return
+		case update := <-u.updates:
+			update()
+		case <-ticker.C:
+			// FastDepth returns a new pointer on updates only
+			if depth := btce.FastDepth(u.pair); depth != nil {
+				u.depth = depth
+			}
+			if time.Since(sized) >= time.Second {
+				rows, cols = term.size()
+				sized = time.Now()
+			}
+		}
+	}
+}
+
+// poll gets recent trades and our orders every interval, or on refresh
+func (u *tradeUI) poll(interval time.Duration) {
+	for {
+		trades, err := u.public.GetTrades([]string{u.pair}, 50)
+		u.updates <- func() {
+			if err != nil {
+				u.message = "Trades: " + err.Error()
+				return
+			}
+			u.trades = trades[u.pair]
+		}
+		if u.client != nil {
+			u.private.Lock()
+			orders, err := u.client.GetActiveOrders(u.pair)
+			u.private.Unlock()
+			u.updates <- func() {
+				if err != nil {
+					u.message = "Orders: " + err.Error()
+					return
+				}
+				u.orders = orders
+			}
+		}
+		select {
+		case <-time.After(interval):
+		case <-u.refresh:
+		}
+	}
+}
+
+func (u *tradeUI) refreshNow() {
+	select {
+	case u.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// key handles a key, returning false to quit
+func (u *tradeUI) key(key string) bool {
+	if key == keyInterrupt {
+		return false
+	}
+	if u.confirm != nil {
+		confirm := u.confirm
+		u.prompt, u.confirm = "", nil
+		if key == "y" || key == "Y" {
+			confirm()
+		} else {
+			u.message = "Not confirmed"
+		}
+		return true
+	}
+	if u.done != nil {
+		switch key {
+		case keyEnter:
+			done := u.done
+			u.prompt, u.done = "", nil
+			done(u.input)
+		case keyEscape:
+			u.prompt, u.done = "", nil
+		case keyBack:
+			if u.input != "" {
+				u.input = u.input[:len(u.input)-1]
+			}
+		default:
+			if len(key) == 1 {
+				u.input += key
+			}
+		}
+		return true
+	}
+	u.message = ""
+	switch key {
+	case "q":
+		return false
+	case keyUp, "k":
+		u.move(-1)
+	case keyDown, "j":
+		u.move(1)
+	case keyPageUp:
+		u.move(-10)
+	case keyPageDown:
+		u.move(10)
+	case "b":
+		u.place("buy")
+	case "s":
+		u.place("sell")
+	case "c":
+		u.cancel()
+	case "a":
+		u.prompt, u.input = "Amount: ", ""
+		u.done = func(s string) {
+			amount, err := strconv.ParseFloat(s, 64)
+			if err != nil || amount <= 0 {
+				u.message = "Invalid amount " + strconv.Quote(s)
+				return
+			}
+			u.amount = amount
+		}
+	case "r":
+		u.refreshNow()
+	}
+	return true
+}
+
+// cursorIndex finds the cursor in u.levels: the level at its rate,
+// or the nearest one on its side; -1 if there are no levels
+func (u *tradeUI) cursorIndex() int {
+	best, bestDiff := -1, math.Inf(1)
+	for i, l := range u.levels {
+		if l.side != u.cursorSide {
+			continue
+		}
+		if u.cursorRate == 0 {
+			return i
+		}
+		if diff := math.Abs(l.rate - u.cursorRate); diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	if best < 0 && len(u.levels) > 0 {
+		// nothing on the side: the level next to the spread
+		if u.cursorSide == "bid" {
+			return len(u.levels) - 1
+		}
+		return 0
+	}
+	return best
+}
+
+func (u *tradeUI) move(delta int) {
+	i := u.cursorIndex()
+	if i < 0 {
+		return
+	}
+	i += delta
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(u.levels) {
+		i = len(u.levels) - 1
+	}
+	u.cursorSide, u.cursorRate = u.levels[i].side, u.levels[i].rate
+}
+
+// mine returns the amount of our orders at a level
+func (u *tradeUI) mine(l bookLevel) float64 {
+	amount := 0.0
+	for _, o := range u.orders {
+		if orderSide(o) == l.side && o.Rate == l.rate {
+			amount += o.Amount
+		}
+	}
+	return amount
+}
+
+// place asks to confirm an order at the cursor's rate, then places it
+func (u *tradeUI) place(typ string) {
+	i := u.cursorIndex()
+	switch {
+	case i < 0:
+		return
+	case u.client == nil:
+		u.message = "Viewing only (-view)"
+		return
+	case u.amount < u.info.MinAmount:
+		u.message = fmt.Sprintf("Amount %v is below the minimum of %v", u.amount, u.info.MinAmount)
+		return
+	}
+	p := btce.TradeParameters{Pair: u.pair, Type: typ, Rate: u.levels[i].rate, Amount: u.amount}
+	u.prompt = fmt.Sprintf("Place %v order: %v %v at %.*f? (y/n)", typ, p.Amount,
+		p.Pair, u.info.DecimalPlaces, p.Rate)
+	u.confirm = func() {
+		u.message = "Placing order..."
+		go func() {
+			u.private.Lock()
+			result, err := u.client.PlaceOrder(p)
+			u.private.Unlock()
+			u.updates <- func() {
+				switch {
+				case err != nil:
+					u.message = "Order failed: " + err.Error()
+				case result.OrderId == 0:
+					u.message = fmt.Sprintf("Order executed, received %v", result.Received)
+				default:
+					u.message = fmt.Sprintf("Order #%v placed, received %v, remains %v",
+						result.OrderId, result.Received, result.Remains)
+				}
+				u.refreshNow()
+			}
+		}()
+	}
+}
+
+// cancel asks to confirm cancelling our orders at the cursor's
+// level, then cancels them
+func (u *tradeUI) cancel() {
+	i := u.cursorIndex()
+	if i < 0 {
+		return
+	}
+	ids := []uint64{}
+	for _, id := range sortedIds(u.orders) {
+		if o := u.orders[id]; orderSide(o) == u.levels[i].side && o.Rate == u.levels[i].rate {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		u.message = "No orders of ours here"
+		return
+	}
+	u.prompt = fmt.Sprintf("Cancel %v order(s) at %.*f? (y/n)", len(ids),
+		u.info.DecimalPlaces, u.levels[i].rate)
+	u.confirm = func() {
+		u.message = "Cancelling..."
+		go func() {
+			failed := []string{}
+			u.private.Lock()
+			for _, id := range ids {
+				if _, err := u.client.Cancel(id); err != nil {
+					failed = append(failed, fmt.Sprintf("#%v: %v", id, err))
+				}
+			}
+			u.private.Unlock()
+			u.updates <- func() {
+				if len(failed) > 0 {
+					u.message = "Cancelling failed: " + strings.Join(failed, "; ")
+				} else {
+					u.message = fmt.Sprintf("Cancelled %v order(s)", len(ids))
+				}
+				u.refreshNow()
+			}
+		}()
+	}
+}
+
+// render returns lines of the screen: the book on the left with
+// recent trades and our orders on the right, then a status line
+func (u *tradeUI) render(rows, cols int) []string {
+	n := (rows - 4) / 2
+	if n < 1 {
+		n = 1
+	}
+	asks, bids := bookLevels(u.depth, n)
+	u.levels = append(append([]bookLevel{}, asks...), bids...)
+	cursor := u.cursorIndex()
+
+	left := cols
+	if cols >= 80 {
+		left = cols * 3 / 5
+	}
+	maxTotal := 0.0
+	for _, l := range u.levels {
+		maxTotal = math.Max(maxTotal, l.total)
+	}
+	book := []string{ansiBold + fit(fmt.Sprintf("%12v %12v %12v %-10v", "RATE", "AMOUNT",
+		"TOTAL", "OURS"), left)}
+	// blank levels keep the spread line in place
+	for i := len(asks); i < n; i++ {
+		book = append(book, fit("", left))
+	}
+	for i, l := range u.levels {
+		if i == len(asks) {
+			book = append(book, u.spreadLine(asks, bids, left))
+		}
+		book = append(book, u.bookRow(l, left, maxTotal, i == cursor))
+	}
+	if len(bids) == 0 {
+		book = append(book, u.spreadLine(asks, bids, left))
+	}
+
+	mode := ""
+	switch {
+	case u.client == nil:
+		mode = "  (viewing only)"
+	case u.client.ReadOnly:
+		mode = "  (read-only)"
+	}
+	lines := []string{ansiBold + fit(fmt.Sprintf("%v  order amount %v  our orders %v%v",
+		u.pair, u.amount, len(u.orders), mode), cols)}
+	side := u.sidePane()
+	for i := 0; i < rows-2; i++ {
+		line := fit("", left)
+		if i < len(book) {
+			line = book[i]
+		}
+		if left < cols {
+			right := ""
+			if i < len(side) {
+				right = side[i]
+			}
+			line += ansiReset + " |" + fit(right, cols-left-2)
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, u.statusLine(cols))
+}
+
+func (u *tradeUI) bookRow(l bookLevel, width int, maxTotal float64, cursor bool) string {
+	style := ansiGreen
+	if l.side == "ask" {
+		style = ansiRed
+	}
+	if cursor {
+		style += ansiReverse
+	}
+	text := fmt.Sprintf("%12.*f %12.8g %12.8g ", u.info.DecimalPlaces, l.rate, l.amount, l.total)
+	textWidth := int(math.Min(float64(len(text)), float64(width)))
+	markWidth := int(math.Min(11, float64(width-textWidth)))
+	barWidth := width - textWidth - markWidth
+	bar := ""
+	if maxTotal > 0 {
+		bar = strings.Repeat("#", int(float64(barWidth)*l.total/maxTotal+0.5))
+	}
+	row := style + fit(text, textWidth)
+	if mine := u.mine(l); mine > 0 {
+		row += ansiYellow + ansiBold + fit(fmt.Sprintf("*%.8g", mine), markWidth) +
+			ansiReset + style
+	} else {
+		row += fit("", markWidth)
+	}
+	return row + fit(bar, barWidth) + ansiReset
+}
+
+// spreadLine shows the spread and mid price between asks and bids
+func (u *tradeUI) spreadLine(asks, bids []bookLevel, width int) string {
+	text := "no spread: one side of the book is empty"
+	if len(asks) > 0 && len(bids) > 0 {
+		ask, bid := asks[len(asks)-1].rate, bids[0].rate
+		text = fmt.Sprintf("spread %.*f (%.3f%%)  mid %.*f", u.info.DecimalPlaces, ask-bid,
+			(ask-bid)/ask*100, u.info.DecimalPlaces+1, (ask+bid)/2)
+	}
+	return ansiBold + fit(fmt.Sprintf("%12v %v", "", text), width) + ansiReset
+}
+
+// sidePane lists recent trades and our orders
+func (u *tradeUI) sidePane() []string {
+	lines := []string{"RECENT TRADES"}
+	for i, t := range u.trades {
+		if i == 15 {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%v %-4v %.*f %.8g",
+			unixTime(t.Timestamp).Format("15:04:05"), t.Type, u.info.DecimalPlaces,
+			t.Price, t.Amount))
+	}
+	lines = append(lines, "", "OUR ORDERS")
+	if u.client == nil {
+		return append(lines, "(viewing only)")
+	}
+	for _, id := range sortedIds(u.orders) {
+		o := u.orders[id]
+		lines = append(lines, fmt.Sprintf("#%v %-4v %.8g at %.*f", id, o.Type, o.Amount,
+			u.info.DecimalPlaces, o.Rate))
+	}
+	return lines
+}
+
+func (u *tradeUI) statusLine(cols int) string {
+	switch {
+	case u.prompt != "":
+		return ansiBold + fit(u.prompt+" "+u.input, cols)
+	case u.message != "":
+		return ansiYellow + fit(u.message, cols)
+	}
+	return fit("up/down j/k: move  b: buy  s: sell  c: cancel ours  a: amount  r: refresh  q: quit", cols)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Private calls of orders placed and of polling don't overlap, as
+// they share the nonce of the client
+func TestTUIPrivateCalls(t *testing.T) {
+	var inFlight int32
+	var mutex sync.Mutex
+	nonces := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/3/") {
+			fmt.Fprint(w, `{"pairs":{"btc_usd":{"decimal_places":3,"min_amount":0.01}},"btc_usd":[]}`)
+			return
+		}
+		if atomic.AddInt32(&inFlight, 1) > 1 {
+			t.Error("Private calls overlap")
+		}
+		defer atomic.AddInt32(&inFlight, -1)
+		r.ParseForm()
+		mutex.Lock()
+		if nonce := r.Form.Get("nonce"); nonces[nonce] {
+			t.Error("Nonce used twice:", nonce)
+		} else {
+			nonces[nonce] = true
+		}
+		mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		switch r.Form.Get("method") {
+		case "Trade":
+			fmt.Fprint(w, `{"success":1,"return":{"order_id":1}}`)
+		default:
+			fmt.Fprint(w, `{"success":0,"error":"no orders"}`)
+		}
+	}))
+	defer server.Close()
+	u := &tradeUI{pair: "btc_usd", info: btce.PairInfo{MinAmount: 0.01}, amount: 1,
+		public: &btce.Client{URL: server.URL}, client: &btce.Client{URL: server.URL},
+		levels: []bookLevel{{"bid", 100, 1, 1}}, cursorSide: "bid",
+		updates: make(chan func(), 16), refresh: make(chan struct{}, 1)}
+	go u.poll(time.Millisecond)
+	for i := 0; i < 5; i++ {
+		u.place("buy")
+		u.confirm()
+	}
+	placed := 0
+	for placed < 5 {
+		(<-u.updates)()
+		if strings.HasPrefix(u.message, "Order #") {
+			placed++
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// confirmOnTerminal asks to type "yes" to confirm a withdrawal
+func confirmOnTerminal(w btce.Withdrawal) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		log.Println("Can't confirm without a terminal:", err)
+		return false
+	}
+	defer tty.Close()
+	to := w.Address
+	if w.Method == "CreateCoupon" {
+		to = "a coupon for " + strconv.Quote(w.Address)
+	}
+	fmt.Fprintf(tty, "%v %.8f %v to %v? Type yes to confirm: ",
+		w.Method, w.Amount, strings.ToUpper(w.Currency), to)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+// loadPolicy reads a withdrawal policy, confirming on the terminal
+func loadPolicy(fileName string) *btce.WithdrawalPolicy {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		log.Fatal("Withdrawal policy is required: ", err)
+	}
+	policy := &btce.WithdrawalPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		log.Fatal(fileName, ": ", err)
+	}
+	policy.Confirm = confirmOnTerminal
+	return policy
+}
+
+// withdraw withdraws coins to a whitelisted address, per policy
+func withdraw(args []string) {
+	f := flag.NewFlagSet("withdrawal parameters", flag.ExitOnError)
+	policyFile := f.String("policy", "withdraw-policy.json",
+		"Withdrawal policy: whitelist, limits and audit log")
+	f.Usage = func() {
+		fmt.Fprintln(f.Output(), "Usage: withdraw [-policy file] <currency> <amount> <address>")
+		f.PrintDefaults()
+	}
+	f.Parse(args)
+	if f.NArg() != 3 {
+		f.Usage()
+		os.Exit(2)
+	}
+	amount, err := strconv.ParseFloat(f.Arg(1), 64)
+	if err != nil {
+		log.Fatal(err)
+	}
+	policy := loadPolicy(*policyFile)
+	c := getClient()
+	policy.Attach(c)
+	result := btce.WithdrawCoinResult{}
+	err = c.Call(btce.WithdrawCoinParameters{CoinName: strings.ToUpper(f.Arg(0)),
+		Amount: amount, Address: f.Arg(2)}, &result)
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := newTable([]column{{"trans_id", "trans id"},
+		{"amount_sent", "amount sent"}}).named("withdrawal")
+	t.add(result.TransId, result.AmountSent)
+	printTables(t, fundsTable(result.Funds))
+}
@@ -3,14 +3,22 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/akovalenko/go-btce"
+	"github.com/akovalenko/go-btce/backtest"
+	"github.com/akovalenko/go-btce/metrics"
+	"github.com/akovalenko/go-btce/paper"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"math"
+	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -28,6 +36,7 @@ type Strategy struct {
 	Capitalize string
 	Unit       float64
 	Upscale    float64
+	Paper      string
 }
 
 type State struct {
@@ -46,7 +55,7 @@ type DynamicData struct {
 func (d DynamicData) FindRate(rate float64, exact bool) int {
 	pos := sort.SearchFloat64s(d.Rates, rate)
 	if exact && d.Rates[pos] != rate {
-		log.Fatal("Exact rate search failed for", rate)
+		fatal("Exact rate search failed for", rate)
 	}
 	return pos
 }
@@ -55,10 +64,11 @@ type Sxcrobot struct {
 	strategy         Strategy
 	state            State
 	data             DynamicData
-	client           *btce.Client
+	exchange         btce.Exchange
+	client           *btce.Client // when exchange is a real client
+	paper            *paper.Exchange
 	info             *btce.PublicInfo
 	loadedKey        bool
-	funds            map[string]float64
 	writeStateCreate bool
 	StrategyFile     string
 	StateFile        string
@@ -85,35 +95,74 @@ func (s *Sxcrobot) SaveState() {
 		file, err := os.Open(s.StateFile)
 		file.Close()
 		if err == nil {
-			log.Fatal("Refuse to overwrite with empty state:", s.StateFile)
+			fatal("Refuse to overwrite with empty state:", s.StateFile)
 		}
 	}
 	saveJSON(s.StateFile, s.state)
 }
 
-func (s *Sxcrobot) EnsureClient() *btce.Client {
-	if s.client == nil {
+// EnsureClient creates a client for the strategy, unless an exchange
+// is already set (like a simulator or a mock)
+func (s *Sxcrobot) EnsureClient() btce.Exchange {
+	if s.exchange == nil {
 		client, err := btce.NewClient(s.strategy.URL)
 		failOn(err)
+		client.Logger = traceLogger()
+		if registry != nil {
+			registry.Attach(client)
+		}
 		s.client = client
+		if s.strategy.Paper != "" {
+			s.LoadPaper(client)
+			s.client = &btce.Client{URL: s.strategy.URL,
+				Backend: s.paper, Logger: client.Logger}
+		}
+		s.exchange = s.client
+	}
+	return s.exchange
+}
+
+// LoadPaper loads paper trading state, or starts paper trading with
+// real account funds if there's no state file yet
+func (s *Sxcrobot) LoadPaper(real *btce.Client) {
+	market := &paper.LiveMarket{Client: real}
+	haveState, err := exists(s.strategy.Paper)
+	failOn(err)
+	if haveState {
+		s.paper, err = paper.Load(market, s.strategy.Paper)
+		failOn(err)
+		return
+	}
+	failOn(real.LoadKey(s.strategy.KeyFile))
+	funds, err := paper.AccountFunds(real)
+	failOn(err)
+	log.Println("Paper trading starts with funds:", funds)
+	s.paper = paper.New(market, funds)
+}
+
+// SavePaper saves paper trading state, if paper trading is used
+func (s *Sxcrobot) SavePaper() {
+	if s.paper != nil {
+		failOn(s.paper.Save(s.strategy.Paper))
 	}
-	return s.client
 }
 
-func (s *Sxcrobot) EnsureKeyedClient() *btce.Client {
+func (s *Sxcrobot) EnsureKeyedClient() btce.Exchange {
 	s.EnsureClient()
-	if !s.loadedKey {
-		err := s.client.ReadKey(s.strategy.KeyFile)
+	if !s.loadedKey && s.paper == nil && s.client != nil {
+		err := s.client.LoadKey(s.strategy.KeyFile)
 		failOn(err)
 		s.loadedKey = true
 	}
-	return s.client
+	return s.exchange
 }
 
 func (s *Sxcrobot) EnsurePublicInfo() *btce.PublicInfo {
 	s.EnsureClient()
 	if s.info == nil {
-		s.info = s.client.PublicInfo()
+		info, err := s.exchange.GetPublicInfo()
+		failOn(err)
+		s.info = info
 	}
 	return s.info
 }
@@ -167,14 +216,27 @@ func (s *Sxcrobot) NextAmount(amount float64, rate float64, movement int) float6
 }
 
 func (s *Sxcrobot) UpdateFunds() {
-	s.funds = s.client.PrivateInfo().Funds
+	if s.client != nil {
+		failOn(s.client.RefreshBalances())
+		return
+	}
+	_, err := s.exchange.GetBalances()
+	failOn(err)
+}
+
+// Available returns funds available for new orders (tracked by the
+// exchange after UpdateFunds)
+func (s *Sxcrobot) Available(currency string) float64 {
+	balances, err := s.exchange.GetBalances()
+	failOn(err)
+	return balances[currency].Available
 }
 
 func (s *Sxcrobot) PlaceOrder(dir string, amount float64, rate float64) {
 	log.Println("Placing order:", dir, "for", amount, "at", rate)
 	param := btce.TradeParameters{Pair: s.strategy.Pair, Type: dir, Rate: rate, Amount: amount}
-	result := s.client.Trade(param)
-	s.funds = result.Funds
+	result, err := s.exchange.PlaceOrder(param)
+	failOn(err)
 	if result.OrderId == 0 {
 		s.RePlaceOrder(dir, amount, rate)
 	} else {
@@ -204,11 +266,11 @@ func (s *Sxcrobot) CmdPlace() {
 	s.EnsurePublicInfo()
 	s.EnsureKeyedClient()
 	s.EnsureData()
-	depths, err := s.client.GetDepth([]string{s.strategy.Pair}, 1)
+	depths, err := s.exchange.GetDepth([]string{s.strategy.Pair}, 1)
 	failOn(err)
 	depth := depths[s.strategy.Pair]
 	if len(depth.Asks) < 1 || len(depth.Bids) < 1 {
-		log.Fatal("Out of asks or bids entirely")
+		fatal("Out of asks or bids entirely")
 	}
 	high := s.data.FindRate(depth.Asks[0].Rate(), false)
 	low := s.data.FindRate(depth.Bids[0].Rate(), false)
@@ -217,7 +279,8 @@ func (s *Sxcrobot) CmdPlace() {
 	low = tipping - 1
 	high = tipping + 1
 
-	active := s.client.ActiveOrders(btce.ActiveOrdersParameters{Pair: s.strategy.Pair})
+	active, err := s.exchange.GetActiveOrders(s.strategy.Pair)
+	failOn(err)
 
 	for _, id := range s.state.Orders {
 		orderdata, ok := active[id]
@@ -242,7 +305,7 @@ func (s *Sxcrobot) CmdPlace() {
 	log.Println("Sell starts at", s.data.Rates[high], "buy at", s.data.Rates[low])
 	s.UpdateFunds()
 	log.Println("Placing sell orders")
-	for s.funds[s.data.BaseUnit] >= s.data.Amounts[high] {
+	for s.Available(s.data.BaseUnit) >= s.data.Amounts[high] {
 		s.PlaceOrder("sell", s.data.Amounts[high], s.data.Rates[high])
 		high++
 	}
@@ -252,7 +315,7 @@ func (s *Sxcrobot) CmdPlace() {
 		futureRate := s.NextRate(rate, 1)
 		futureAmount := s.data.Amounts[s.data.FindRate(futureRate, true)]
 		thisAmount := s.NextAmount(futureAmount, futureRate, -1)
-		if s.funds[s.data.OtherUnit] < rate*thisAmount {
+		if s.Available(s.data.OtherUnit) < rate*thisAmount {
 			break
 		}
 		s.PlaceOrder("buy", thisAmount, rate)
@@ -272,7 +335,8 @@ func (s *Sxcrobot) CmdUpdate() {
 	log.Println("Calculating data...")
 	s.EnsureData()
 	log.Println("Calling ActiveOrders..")
-	active := s.client.ActiveOrders(btce.ActiveOrdersParameters{Pair: s.strategy.Pair})
+	active, err := s.exchange.GetActiveOrders(s.strategy.Pair)
+	failOn(err)
 	log.Println("Found active orders:", len(active), "/ known:", len(s.state.Orders))
 	var changed bool
 	for index, id := range s.state.Orders {
@@ -280,7 +344,8 @@ func (s *Sxcrobot) CmdUpdate() {
 		_, ok := active[id]
 		if !ok {
 			log.Println("Order went away:", id)
-			orderinfo := s.client.OrderInfo(btce.OrderInfoParameters{id})[id]
+			orderinfo, err := s.exchange.GetOrderInfo(id)
+			failOn(err)
 			s.state.Orders[index] = 0 // wipe
 			changed = true
 			if orderinfo.Status == 1 {
@@ -302,13 +367,13 @@ func (s *Sxcrobot) CmdCancel() {
 	s.EnsurePublicInfo()
 	s.EnsureKeyedClient()
 	s.EnsureData()
-	active := s.client.ActiveOrders(btce.ActiveOrdersParameters{Pair: s.strategy.Pair})
+	active, err := s.exchange.GetActiveOrders(s.strategy.Pair)
+	failOn(err)
 	var changed bool
 	for index, id := range s.state.Orders {
 		_, ok := active[id]
 		if ok {
-			result := btce.CancelOrderResult{}
-			err := s.client.Call(btce.CancelOrderParameters{id}, &result)
+			_, err := s.exchange.Cancel(id)
 			if err != nil {
 				log.Println("Skipping order #", id, ":", err)
 			}
@@ -321,17 +386,129 @@ func (s *Sxcrobot) CmdCancel() {
 	}
 }
 
+// CmdBacktest runs the strategy over recorded market data, with a
+// temporary state file and a simulated account. It never talks to
+// the real server, except for public info missing from the data.
+func (s *Sxcrobot) CmdBacktest(args []string) error {
+	f := flag.NewFlagSet("backtest parameters", flag.ExitOnError)
+	fundsFile := f.String("funds", "funds.json", `Initial funds; {"usd": 1000, "btc": 1}`)
+	interval := f.Duration("interval", time.Minute, "Update interval, in simulated time")
+	f.Parse(args)
+	s.LoadStrategy()
+	funds, err := paper.LoadFunds(*fundsFile)
+	failOn(err)
+	events, err := backtest.LoadFiles(f.Args()...)
+	failOn(err)
+	dir, err := ioutil.TempDir("", "simplexchange")
+	failOn(err)
+	defer os.RemoveAll(dir)
+	s.StateFile = filepath.Join(dir, "state.json")
+	s.CmdInit()
+	s.writeStateCreate = false
+
+	b := &backtest.Backtest{Events: events, Funds: funds,
+		Currency: s.strategy.ToDynamicData().OtherUnit, Interval: *interval}
+	hasInfo := false
+	for _, e := range events {
+		hasInfo = hasInfo || e.Info != nil
+	}
+	if !hasInfo {
+		// a throwaway client, so that the strategy never gets a real one
+		client, err := btce.NewClient(s.strategy.URL)
+		if err != nil {
+			return err
+		}
+		if b.Info, err = client.GetPublicInfo(); err != nil {
+			return err
+		}
+	}
+	placed := false
+	b.Strategy = backtest.StrategyFunc(func(c *btce.Client, now time.Time) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				failure, ok := r.(strategyError)
+				if !ok {
+					panic(r)
+				}
+				err = failure.error
+			}
+		}()
+		s.exchange, s.client, s.paper, s.info = c, nil, nil, nil
+		if placed {
+			s.CmdUpdate()
+		} else {
+			s.CmdPlace()
+			placed = true
+		}
+		return nil
+	})
+	// the strategy log is only shown when it fails
+	var strategyLog bytes.Buffer
+	log.SetOutput(&strategyLog)
+	fatal = func(v ...interface{}) { panic(strategyError{errors.New(fmt.Sprint(v...))}) }
+	r, err := b.Run()
+	log.SetOutput(os.Stderr)
+	fatal = log.Fatal
+	if err != nil {
+		os.Stderr.Write(strategyLog.Bytes())
+		return err
+	}
+
+	fmt.Println("Period:", r.Start, "-", r.End, "steps:", r.Steps, "fills:", len(r.Fills))
+	fmt.Printf("Equity: %.8f -> %.8f %v, PnL %.8f, max drawdown %.2f%%\n",
+		r.StartEquity, r.EndEquity, b.Currency, r.PnL, r.MaxDrawdown*100)
+	for _, p := range r.Pairs {
+		fmt.Printf("%v: realized %.8f, unrealized %.8f %v, holding %.8f\n",
+			p.Pair, p.Realized, p.Unrealized, p.Currency, p.Amount)
+	}
+	fmt.Println("Final funds:", r.Funds)
+	return nil
+}
+
 var strategyFile string
 var stateFile string
+var traceRpc bool
+var metricsAddr string
+var registry *metrics.Registry
 
 func init() {
 	flag.StringVar(&strategyFile, "strategy", "strategy.json", "Strategy file")
 	flag.StringVar(&stateFile, "state", "state.json", "State file")
+	flag.BoolVar(&traceRpc, "traceRpc", false, "Trace BTC-e RPC calls")
+	flag.StringVar(&metricsAddr, "metrics", "",
+		"Serve Prometheus metrics on this address (like :9100)")
+}
+
+// serveMetrics starts serving /metrics with -metrics
+func serveMetrics() {
+	if metricsAddr == "" {
+		return
+	}
+	registry = metrics.New()
+	http.Handle("/metrics", registry)
+	go func() { log.Fatal(http.ListenAndServe(metricsAddr, nil)) }()
 }
 
+// traceLogger returns a debug logger to stderr with -traceRpc, nil
+// otherwise
+func traceLogger() *slog.Logger {
+	if !traceRpc {
+		return nil
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr,
+		&slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// fatal stops the program like log.Fatal; a backtest replaces it to
+// panic with a strategyError instead, which the strategy callback
+// turns back into an error
+var fatal = log.Fatal
+
+type strategyError struct{ error }
+
 func failOn(err error) {
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 }
 
@@ -349,11 +526,11 @@ func (s Strategy) ToDynamicData() DynamicData {
 	d.BaseUnit, d.OtherUnit = cur[0], cur[1]
 	d.FixInBase = (d.BaseUnit == s.Capitalize)
 	if !d.FixInBase && d.OtherUnit != s.Capitalize {
-		log.Fatal("Unknown currency for capitalization:", s.Capitalize)
+		fatal("Unknown currency for capitalization:", s.Capitalize)
 	}
 	if s.Base < s.Min || s.Base > s.Max || s.Base <= 0 ||
 		s.Min <= 0 || s.Max <= 0 || s.Step <= 0 || s.Unit <= 0 || s.Upscale <= 0 {
-		log.Fatal("Contradictive or invalid rate boundaries")
+		fatal("Contradictive or invalid rate boundaries")
 	}
 	stepsDown := 0
 	stepsUp := 0
@@ -365,7 +542,7 @@ func (s Strategy) ToDynamicData() DynamicData {
 	}
 	count := stepsUp + stepsDown - 1
 	if count < 1 || count > 100000 {
-		log.Fatal("Something went wrong with levels")
+		fatal("Something went wrong with levels")
 	}
 	d.Amounts = make([]float64, count)
 	d.Rates = make([]float64, count)
@@ -415,6 +592,7 @@ func exists(path string) (bool, error) {
 
 func main() {
 	flag.Parse()
+	serveMetrics()
 	bot := Sxcrobot{StrategyFile: strategyFile, StateFile: stateFile}
 	switch flag.Arg(0) {
 	case "init":
@@ -423,20 +601,26 @@ func main() {
 	case "place":
 		log.Println("Placing freed capital to orders")
 		bot.CmdPlace()
+		bot.SavePaper()
 	case "update":
 		log.Println("Updating orders by closure")
 		bot.CmdUpdate()
+		bot.SavePaper()
 	case "cancel":
 		log.Println("Updating orders by closure")
 		bot.CmdCancel()
+		bot.SavePaper()
 	case "monitor":
 		for {
 			log.Println("Updating...")
 			bot.CmdUpdate()
+			bot.SavePaper()
 			log.Println("Sleeping...")
 			time.Sleep(2 * time.Second)
 
 		}
+	case "backtest":
+		failOn(bot.CmdBacktest(flag.Args()[1:]))
 	case "example":
 		haveStrategy, _ := exists(strategyFile)
 		if haveStrategy {
@@ -450,7 +634,7 @@ func main() {
 		if haveKey {
 			fmt.Println("File ", keyFile, " already exists, not writing example")
 		} else {
-			ioutil.WriteFile(keyFile, []byte(exampleKey), 0644)
+			ioutil.WriteFile(keyFile, []byte(exampleKey), 0600)
 			fmt.Println("Created key file example: ", keyFile)
 		}
 	default:
@@ -462,6 +646,8 @@ func main() {
     update (replace corresponding orders for closed orders)
     monitor (constantly update while running)
     cancel (cancel every order managed by simplexchange)
+    backtest [-funds funds.json] [-interval 1m] data.jsonl...
+      (run over recorded market data with simulated funds)
 
     example (create same strategy.json and key.json when they don't exist)
 
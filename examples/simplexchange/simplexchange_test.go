@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testStrategy = `{"pair": "btc_usd", "base": 100, "step": 1.01, "min": 50,
+"max": 200, "keyfile": "%v", "url": "%v", "spread": 1.006,
+"capitalize": "usd", "unit": 0.01, "upscale": 1}`
+
+// runBacktest runs the backtest command over events, with a server that
+// only answers public info requests
+func runBacktest(t *testing.T, events string) error {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/3/info") {
+			t.Error("Backtest called the server:", r.URL.Path)
+			http.Error(w, "unexpected", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"pairs":{"btc_usd":{"decimal_places":3,"min_amount":0.001,"max_price":10000,"fee":0.2}}}`)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	key := write("key.json", `{"key": "K", "secret": "00"}`)
+	s := &Sxcrobot{StrategyFile: write("strategy.json",
+		fmt.Sprintf(testStrategy, key, server.URL))}
+	return s.CmdBacktest([]string{
+		"-funds", write("funds.json", `{"usd": 5, "btc": 0.05}`),
+		write("data.jsonl", events)})
+}
+
+// Without recorded info, the backtest gets it from the server, but
+// never gives the strategy a real client
+func TestBacktestOffline(t *testing.T) {
+	events := `{"time":"2017-01-01T00:00:00Z","pair":"btc_usd","depth":{"asks":[[100.5,5]],"bids":[[99.5,5]]}}
+{"time":"2017-01-01T00:01:00Z","pair":"btc_usd","depth":{"asks":[[103,5]],"bids":[[102,5]]}}
+{"time":"2017-01-01T00:02:00Z","pair":"btc_usd","depth":{"asks":[[98,5]],"bids":[[97,5]]}}
+`
+	if err := runBacktest(t, events); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Strategy failures end the backtest with an error, not an exit
+func TestBacktestFailure(t *testing.T) {
+	events := `{"time":"2017-01-01T00:00:00Z","pair":"btc_usd","depth":{"asks":[[100.5,5]],"bids":[]}}
+`
+	err := runBacktest(t, events)
+	if err == nil || !strings.Contains(err.Error(), "Out of asks or bids") {
+		t.Fatal(err)
+	}
+}
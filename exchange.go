@@ -0,0 +1,70 @@
+package btce
+
+// MarketData is the public part of the API a strategy needs. Besides
+// *Client, it can be implemented by caches, simulators and mocks.
+type MarketData interface {
+	GetTicker(pairs []string) (map[string]TickerInfo, error)
+	GetDepth(pairs []string, limit uint) (map[string]DepthInfo, error)
+	GetPublicInfo() (*PublicInfo, error)
+	GetTrades(pairs []string, limit uint) (map[string][]TradeInfo, error)
+}
+
+// Trader is the order management part of the private API a strategy
+// needs.
+type Trader interface {
+	PlaceOrder(p TradeParameters) (TradeResult, error)
+	Cancel(orderId uint64) (CancelOrderResult, error)
+	GetActiveOrders(pair string) (ActiveOrdersResult, error)
+	GetOrderInfo(orderId uint64) (OrderInfo, error)
+	GetBalances() (map[string]Balance, error)
+}
+
+// Exchange is everything a strategy needs: market data and trading.
+type Exchange interface {
+	MarketData
+	Trader
+}
+
+var _ Exchange = (*Client)(nil)
+
+// PlaceOrder calls private API method Trade, returning an error
+// instead of panicking.
+func (c *Client) PlaceOrder(p TradeParameters) (TradeResult, error) {
+	result := TradeResult{}
+	err := c.Call(p, &result)
+	return result, err
+}
+
+// Cancel calls private API method CancelOrder, returning an error
+// instead of panicking.
+func (c *Client) Cancel(orderId uint64) (CancelOrderResult, error) {
+	result := CancelOrderResult{}
+	err := c.Call(CancelOrderParameters{OrderId: orderId}, &result)
+	return result, err
+}
+
+// GetActiveOrders calls private API method ActiveOrders for a pair
+// (all pairs if empty), returning an error instead of panicking.
+func (c *Client) GetActiveOrders(pair string) (ActiveOrdersResult, error) {
+	result := ActiveOrdersResult{}
+	err := c.Call(ActiveOrdersParameters{Pair: pair}, &result)
+	return result, err
+}
+
+// GetOrderInfo calls private API method OrderInfo for a single
+// order, returning an error instead of panicking.
+func (c *Client) GetOrderInfo(orderId uint64) (OrderInfo, error) {
+	return c.orderInfo(orderId)
+}
+
+// GetBalances returns balances tracked by c.Balances. When there's
+// no tracker yet, it's created and filled with RefreshBalances;
+// later calls don't need the network.
+func (c *Client) GetBalances() (map[string]Balance, error) {
+	if c.Balances == nil {
+		if err := c.RefreshBalances(); err != nil {
+			return nil, err
+		}
+	}
+	return c.Balances.All(), nil
+}
@@ -0,0 +1,40 @@
+package btce
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestExchangeClient(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	f.Methods["getInfo"] = func(url.Values) (interface{}, error) {
+		return GetInfoResult{Funds: map[string]float64{"usd": 100}}, nil
+	}
+	f.Methods["ActiveOrders"] = func(url.Values) (interface{}, error) {
+		return ActiveOrdersResult{3: {Pair: "btc_usd", Type: "buy", Amount: 1, Rate: 20}}, nil
+	}
+	f.Methods["Trade"] = func(url.Values) (interface{}, error) {
+		return TradeResult{OrderId: 4, Remains: 1,
+			Funds: map[string]float64{"usd": 70}}, nil
+	}
+	var ex Exchange = f.client()
+	balances, err := ex.GetBalances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usd := balances["usd"]; usd.Available != 100 || usd.Reserved != 20 {
+		t.Errorf("initial balance: %+v", usd)
+	}
+	if _, err := ex.PlaceOrder(TradeParameters{Pair: "btc_usd",
+		Type: "buy", Rate: 30, Amount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	balances, _ = ex.GetBalances()
+	if usd := balances["usd"]; usd.Available != 70 || usd.Reserved != 50 {
+		t.Errorf("balance after trade: %+v", usd)
+	}
+	if _, err := ex.Cancel(5); err == nil {
+		t.Error("expected an error for unhandled CancelOrder")
+	}
+}
@@ -0,0 +1,158 @@
+// Package exec implements execution algorithms for amounts too large
+// to be placed as a single order: iceberg orders, TWAP (time-weighted
+// slices) and POV (participation in market volume).
+//
+// Each algorithm is configured with a struct and started with Run,
+// which blocks until the whole amount is executed, the context is
+// cancelled or an error happens. Run always returns the progress
+// achieved so far, so the caller knows what was executed even on
+// failure. An optional OnProgress callback is called after each
+// change.
+package exec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Progress describes the state of an execution.
+type Progress struct {
+	Target float64 // amount to execute
+	Filled float64 // amount executed so far
+	Cost   float64 // Filled valued at execution rates (quote currency)
+	Orders int     // number of orders placed
+}
+
+// Remaining returns the amount left to execute.
+func (p Progress) Remaining() float64 { return p.Target - p.Filled }
+
+// AveragePrice returns the average execution rate, or zero if
+// nothing was executed.
+func (p Progress) AveragePrice() float64 {
+	if p.Filled == 0 {
+		return 0
+	}
+	return p.Cost / p.Filled
+}
+
+// Done reports whether the remaining amount can't be placed anymore,
+// being less than minAmount.
+func (p Progress) Done(minAmount float64) bool {
+	return p.Remaining() <= 0 || p.Remaining() < minAmount
+}
+
+func (p *Progress) add(amount float64, rate float64) {
+	p.Filled += amount
+	p.Cost += amount * rate
+}
+
+// report calls a progress callback if it's set.
+func report(callback func(Progress), p Progress) {
+	if callback != nil {
+		callback(p)
+	}
+}
+
+// pairInfo returns limits and fee of a pair.
+func pairInfo(c *btce.Client, pair string) (btce.PairInfo, error) {
+	info, err := c.GetPublicInfo()
+	if err != nil {
+		return btce.PairInfo{}, err
+	}
+	pi, ok := info.Pairs[pair]
+	if !ok {
+		return pi, errors.New("Unknown pair: " + pair)
+	}
+	return pi, nil
+}
+
+// sliceAmount ensures that a slice is not below minimum amount and
+// doesn't leave an unplaceable remainder.
+func sliceAmount(amount float64, remaining float64, minAmount float64) float64 {
+	if amount < minAmount {
+		amount = minAmount
+	}
+	if amount > remaining || remaining-amount < minAmount {
+		amount = remaining
+	}
+	return amount
+}
+
+// wait sleeps for d, returning early with an error if ctx is done.
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// marketSlice executes amount with an emulated market order,
+// cancelling whatever remains unfilled, and returns the amount
+// executed and its average rate. The order may be filled further
+// between placing and cancelling it (even completely, so cancelling
+// fails): that part is found with OrderInfo and valued at the
+// order's rate.
+func marketSlice(c *btce.Client, kind string, pair string, amount float64, maxSlippage float64) (float64, float64, error) {
+	var r *btce.MarketResult
+	var err error
+	if kind == "buy" {
+		r, err = c.MarketBuy(pair, amount, maxSlippage)
+	} else {
+		r, err = c.MarketSell(pair, amount, maxSlippage)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	rate := r.ActualPrice
+	if rate == 0 {
+		rate = r.ExpectedPrice
+	}
+	filled, cost := r.Filled, r.Filled*rate
+	if id := r.Trade.OrderId; id != 0 {
+		err = c.Call(btce.CancelOrderParameters{OrderId: id}, &btce.CancelOrderResult{})
+		info, infoErr := c.GetOrderInfo(id)
+		switch {
+		case infoErr != nil:
+			if err == nil {
+				err = infoErr
+			}
+		case info.Status == 1:
+			// executed before it could be cancelled
+			err = nil
+			fallthrough
+		default:
+			if late := info.StartAmount - info.Amount - r.Filled; late > 0 {
+				filled += late
+				cost += late * r.Rate
+			}
+		}
+	}
+	if filled == 0 {
+		return 0, rate, err
+	}
+	return filled, cost / filled, err
+}
+
+// takerRate derives the average rate of the part of an order
+// executed when it was placed from the change of quote currency
+// funds, like btce.MarketResult.ActualPrice. The order's rate is
+// returned when funds are unknown.
+func takerRate(p btce.TradeParameters, fee float64, before map[string]float64, result btce.TradeResult, filled float64) float64 {
+	if before == nil || result.Funds == nil || filled <= 0 {
+		return p.Rate
+	}
+	quote := p.Pair[strings.LastIndex(p.Pair, "_")+1:]
+	change := result.Funds[quote] - before[quote]
+	if p.Type == "buy" {
+		return (-change - result.Remains*p.Rate) / filled
+	}
+	return change / ((100 - fee) / 100) / filled
+}
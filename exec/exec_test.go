@@ -0,0 +1,181 @@
+package exec
+
+import (
+	"context"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+	"github.com/akovalenko/go-btce/backtest"
+	"github.com/akovalenko/go-btce/paper"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+// hooked is a paper exchange changing the market before private
+// calls, as set up by a test
+type hooked struct {
+	*paper.Exchange
+	before func(method string)
+}
+
+func (h *hooked) RemoteCall(param map[string]string) (*btce.RemoteResult, error) {
+	if h.before != nil {
+		h.before(param["method"])
+	}
+	return h.Exchange.RemoteCall(param)
+}
+
+func (h *hooked) PublicCall(method string, pairs []string, values *url.Values) ([]byte, error) {
+	if h.before != nil {
+		h.before(method)
+	}
+	return h.Exchange.PublicCall(method, pairs, values)
+}
+
+// newMarket returns a market for btc_usd with asks as given, and a
+// client trading on it with 1000 usd
+func newMarket(asks ...btce.Offer) (*backtest.Replay, *hooked, *btce.Client) {
+	market := backtest.NewReplay(&btce.PublicInfo{Pairs: map[string]btce.PairInfo{
+		"btc_usd": {DecimalPlaces: 3, MinAmount: 0.1, MaxPrice: 1000}}})
+	market.Apply(backtest.Event{Pair: "btc_usd", Depth: &btce.DepthInfo{Asks: asks}})
+	exchange := &hooked{Exchange: paper.New(market, map[string]float64{"usd": 1000})}
+	return market, exchange, &btce.Client{Backend: exchange}
+}
+
+func TestTWAP(t *testing.T) {
+	_, _, c := newMarket(btce.Offer{100, 10})
+	reports := 0
+	twap := &TWAP{Client: c, Pair: "btc_usd", Type: "buy", Amount: 3, Slices: 3,
+		MaxSlippage: 0.01, OnProgress: func(Progress) { reports++ }}
+	p, err := twap.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Orders != 3 || reports != 3 || !near(p.Filled, 3) || !near(p.AveragePrice(), 100) {
+		t.Errorf("%+v, %v reports", p, reports)
+	}
+}
+
+func TestTWAPCancelled(t *testing.T) {
+	_, _, c := newMarket(btce.Offer{100, 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	twap := &TWAP{Client: c, Pair: "btc_usd", Type: "buy", Amount: 3, Slices: 3,
+		MaxSlippage: 0.01, OnProgress: func(Progress) { cancel() }}
+	p, err := twap.Run(ctx)
+	if err != context.Canceled || p.Orders != 1 || !near(p.Filled, 1) {
+		t.Fatalf("%+v %v", p, err)
+	}
+	// cancelled before the first slice
+	p, err = twap.Run(ctx)
+	if err != context.Canceled || p.Orders != 0 {
+		t.Errorf("%+v %v", p, err)
+	}
+}
+
+// A slice not filled at once, then filled partly by a public trade
+// before it's cancelled
+func TestMarketSliceLateFill(t *testing.T) {
+	market, exchange, c := newMarket(btce.Offer{100, 0.4}, btce.Offer{101, 0.6})
+	exchange.before = func(method string) {
+		switch method {
+		case "Trade":
+			// the 101 ask is taken by someone else meanwhile
+			market.Apply(backtest.Event{Pair: "btc_usd",
+				Depth: &btce.DepthInfo{Asks: []btce.Offer{{100, 0.4}}}})
+		case "CancelOrder":
+			market.Apply(backtest.Event{Pair: "btc_usd",
+				Depth:  &btce.DepthInfo{Asks: []btce.Offer{{102, 5}}},
+				Trades: []btce.TradeInfo{{TradeId: 1, Price: 101, Amount: 0.2}}})
+		}
+	}
+	filled, rate, err := marketSlice(c, "buy", "btc_usd", 1, 0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(filled, 0.6) || !near(rate, (0.4*100+0.2*101)/0.6) {
+		t.Error("filled", filled, "at", rate)
+	}
+}
+
+// A slice filled completely between placing and cancelling it
+func TestMarketSliceFilledBeforeCancel(t *testing.T) {
+	market, exchange, c := newMarket(btce.Offer{100, 0.4}, btce.Offer{101, 0.6})
+	exchange.before = func(method string) {
+		switch method {
+		case "Trade":
+			market.Apply(backtest.Event{Pair: "btc_usd",
+				Depth: &btce.DepthInfo{Asks: []btce.Offer{{100, 0.4}}}})
+		case "CancelOrder":
+			market.Apply(backtest.Event{Pair: "btc_usd",
+				Depth:  &btce.DepthInfo{Asks: []btce.Offer{{102, 5}}},
+				Trades: []btce.TradeInfo{{TradeId: 1, Price: 101, Amount: 1}}})
+		}
+	}
+	filled, rate, err := marketSlice(c, "buy", "btc_usd", 1, 0.05)
+	if err != nil || !near(filled, 1) || !near(rate, 0.4*100+0.6*101) {
+		t.Error("filled", filled, "at", rate, err)
+	}
+}
+
+// Each clip takes 0.5 from the 99 ask at once, and the rest is filled
+// at the clip's rate while it waits
+func TestIceberg(t *testing.T) {
+	_, _, c := newMarket(btce.Offer{99, 0.5})
+	ice := &Iceberg{Client: c, Pair: "btc_usd", Type: "buy", Rate: 100,
+		Amount: 2, Clip: 1, Interval: time.Millisecond}
+	p, err := ice.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Orders != 2 || !near(p.Filled, 2) || !near(p.Cost, 2*(0.5*99+0.5*100)) {
+		t.Errorf("%+v", p)
+	}
+}
+
+func TestIcebergCancelled(t *testing.T) {
+	_, exchange, c := newMarket()
+	ctx, cancel := context.WithCancel(context.Background())
+	ice := &Iceberg{Client: c, Pair: "btc_usd", Type: "buy", Rate: 100,
+		Amount: 2, Clip: 1, Interval: time.Millisecond,
+		OnProgress: func(Progress) { cancel() }}
+	p, err := ice.Run(ctx)
+	if err != context.Canceled || p.Orders != 1 || p.Filled != 0 {
+		t.Fatalf("%+v %v", p, err)
+	}
+	for id, o := range exchange.State.Orders {
+		if o.Status != 2 {
+			t.Errorf("clip #%v not cancelled: %+v", id, o)
+		}
+	}
+}
+
+// Each poll sees a new public trade of 2 btc, so POV takes 1 of it
+// at 50% participation (none on the first poll, setting the start)
+func TestPOV(t *testing.T) {
+	market, exchange, c := newMarket(btce.Offer{100, 10})
+	var tid uint64
+	exchange.before = func(method string) {
+		if method == "trades" {
+			tid++
+			market.Apply(backtest.Event{Pair: "btc_usd",
+				Trades: []btce.TradeInfo{{TradeId: tid, Price: 100, Amount: 2}}})
+		}
+	}
+	pov := &POV{Client: c, Pair: "btc_usd", Type: "buy", Amount: 2,
+		Participation: 0.5, Interval: time.Millisecond, MaxSlippage: 0.01}
+	p, err := pov.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Orders != 2 || !near(p.Filled, 2) || !near(p.AveragePrice(), 100) || tid != 3 {
+		t.Errorf("%+v after %v polls", p, tid)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if p, err := pov.Run(ctx); err != context.Canceled || p.Orders != 0 {
+		t.Errorf("%+v %v", p, err)
+	}
+}
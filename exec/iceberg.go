@@ -0,0 +1,102 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Iceberg executes a large limit order showing only a part of it
+// (a clip) at a time; when a clip is filled, the next one is placed.
+type Iceberg struct {
+	Client     *btce.Client
+	Pair       string
+	Type       string  // "buy" or "sell"
+	Rate       float64 // limit rate for all clips
+	Amount     float64 // total amount
+	Clip       float64 // visible amount
+	Interval   time.Duration
+	OnProgress func(Progress)
+}
+
+// DefaultInterval is used for polling when Interval is zero.
+const DefaultInterval = 5 * time.Second
+
+// Run places clips until the total amount is filled. When ctx is
+// cancelled, the current clip is cancelled too, and ctx.Err() is
+// returned along with the progress. A clip cancelled by someone else
+// stops the iceberg with an error.
+func (ice *Iceberg) Run(ctx context.Context) (Progress, error) {
+	progress := Progress{Target: ice.Amount}
+	pi, err := pairInfo(ice.Client, ice.Pair)
+	if err != nil {
+		return progress, err
+	}
+	interval := ice.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	manager, err := btce.NewOrderManager(ice.Client, btce.MemoryOrderStore{})
+	if err != nil {
+		return progress, err
+	}
+	var cancelled []uint64
+	placing, immediate := false, 0.0
+	manager.OnEvent(func(e btce.OrderEvent) {
+		switch e.Type {
+		case btce.OrderPartiallyFilled, btce.OrderFilled:
+			if placing {
+				// valued when the trade result is known
+				immediate += e.Filled
+				return
+			}
+			// a waiting clip is filled at its own rate
+			progress.add(e.Filled, ice.Rate)
+			report(ice.OnProgress, progress)
+		case btce.OrderCancelled:
+			cancelled = append(cancelled, e.Order.Id)
+		}
+	})
+
+	for !progress.Done(pi.MinAmount) {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		p := btce.TradeParameters{Pair: ice.Pair, Type: ice.Type, Rate: ice.Rate,
+			Amount: sliceAmount(ice.Clip, progress.Remaining(), pi.MinAmount)}
+		// a clip crossing the book is filled at better rates at once
+		before := btce.GetInfoResult{}
+		if err := ice.Client.Call(btce.GetInfoParameters{}, &before); err != nil {
+			return progress, err
+		}
+		placing, immediate = true, 0
+		result, err := manager.Place(p)
+		placing = false
+		if err != nil {
+			return progress, err
+		}
+		progress.Orders++
+		if immediate > 0 {
+			progress.add(immediate, takerRate(p, pi.Fee, before.Funds, result, immediate))
+		}
+		report(ice.OnProgress, progress)
+		for id := result.OrderId; len(manager.Orders()) > 0; {
+			if err := wait(ctx, interval); err != nil {
+				if _, cerr := manager.Cancel(id); cerr != nil {
+					return progress, cerr
+				}
+				return progress, err
+			}
+			if err := manager.Poll(); err != nil {
+				return progress, err
+			}
+		}
+		if len(cancelled) > 0 {
+			return progress, fmt.Errorf("Iceberg clip #%v was cancelled",
+				cancelled[0])
+		}
+	}
+	return progress, nil
+}
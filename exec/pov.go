@@ -0,0 +1,86 @@
+package exec
+
+import (
+	"context"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// POV executes an amount in market slices sized as a fraction of the
+// volume traded by the market, as seen in public trades, since the
+// previous slice. Our own trades are counted in that volume too.
+type POV struct {
+	Client        *btce.Client
+	Pair          string
+	Type          string // "buy" or "sell"
+	Amount        float64
+	Participation float64 // fraction of market volume, e.g. 0.1
+	Interval      time.Duration
+	MaxSlippage   float64 // per slice, see btce.Client.MarketBuy
+	OnProgress    func(Progress)
+}
+
+// TradesLimit is the number of public trades fetched per poll by POV.
+var TradesLimit uint = 1000
+
+// Run polls public trades every Interval, placing a slice whenever
+// the participation amount reaches the pair's minimum order amount,
+// until the whole amount is filled or ctx is cancelled.
+func (p *POV) Run(ctx context.Context) (Progress, error) {
+	progress := Progress{Target: p.Amount}
+	pi, err := pairInfo(p.Client, p.Pair)
+	if err != nil {
+		return progress, err
+	}
+	interval := p.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	var lastId uint64
+	owed := 0.0
+	for !progress.Done(pi.MinAmount) {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		trades, err := p.Client.GetTrades([]string{p.Pair}, TradesLimit)
+		if err != nil {
+			return progress, err
+		}
+		volume, newest := 0.0, lastId
+		for _, trade := range trades[p.Pair] {
+			if trade.TradeId > lastId {
+				volume += trade.Amount
+			}
+			if trade.TradeId > newest {
+				newest = trade.TradeId
+			}
+		}
+		if lastId != 0 {
+			owed += volume * p.Participation
+		}
+		lastId = newest
+		if owed >= pi.MinAmount {
+			amount := sliceAmount(owed, progress.Remaining(), pi.MinAmount)
+			filled, rate, err := marketSlice(p.Client, p.Type, p.Pair,
+				amount, p.MaxSlippage)
+			progress.Orders++
+			progress.add(filled, rate)
+			owed -= filled
+			if owed < 0 {
+				owed = 0
+			}
+			report(p.OnProgress, progress)
+			if err != nil {
+				return progress, err
+			}
+			if progress.Done(pi.MinAmount) {
+				break
+			}
+		}
+		if err := wait(ctx, interval); err != nil {
+			return progress, err
+		}
+	}
+	return progress, nil
+}
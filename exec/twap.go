@@ -0,0 +1,77 @@
+package exec
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// TWAP executes an amount in market slices spread evenly over a
+// duration. Jitter (from 0 to 1) randomizes both slice sizes and the
+// pauses between them by up to that fraction, so the pattern is less
+// obvious to other traders.
+type TWAP struct {
+	Client      *btce.Client
+	Pair        string
+	Type        string // "buy" or "sell"
+	Amount      float64
+	Duration    time.Duration
+	Slices      int
+	Jitter      float64
+	MaxSlippage float64 // per slice, see btce.Client.MarketBuy
+	OnProgress  func(Progress)
+	Rand        *rand.Rand // random source; a time-seeded one if nil
+}
+
+func (t *TWAP) jitter(r *rand.Rand, value float64) float64 {
+	return value * (1 + t.Jitter*(2*r.Float64()-1))
+}
+
+// Run executes slices until the whole amount is filled or ctx is
+// cancelled. Unfilled parts of slices are cancelled immediately and
+// carried over to the next slices; the last slice takes whatever
+// remains.
+func (t *TWAP) Run(ctx context.Context) (Progress, error) {
+	progress := Progress{Target: t.Amount}
+	pi, err := pairInfo(t.Client, t.Pair)
+	if err != nil {
+		return progress, err
+	}
+	r := t.Rand
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	slices := t.Slices
+	if slices < 1 {
+		slices = 1
+	}
+	interval := t.Duration / time.Duration(slices)
+	for i := 0; !progress.Done(pi.MinAmount); i++ {
+		if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+		amount := progress.Remaining()
+		if left := slices - i; left > 1 {
+			amount = t.jitter(r, amount/float64(left))
+		}
+		amount = sliceAmount(amount, progress.Remaining(), pi.MinAmount)
+		filled, rate, err := marketSlice(t.Client, t.Type, t.Pair,
+			amount, t.MaxSlippage)
+		progress.Orders++
+		progress.add(filled, rate)
+		report(t.OnProgress, progress)
+		if err != nil {
+			return progress, err
+		}
+		if progress.Done(pi.MinAmount) {
+			break
+		}
+		pause := time.Duration(t.jitter(r, float64(interval)))
+		if err := wait(ctx, pause); err != nil {
+			return progress, err
+		}
+	}
+	return progress, nil
+}
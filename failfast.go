@@ -18,6 +18,15 @@ func (c *Client) Depth(pairs []string, limit uint) map[string]DepthInfo {
 	return result
 }
 
+// Trades is a single-return, panicking-on-error wrapper for GetTrades
+func (c *Client) Trades(pairs []string, limit uint) map[string][]TradeInfo {
+	result, err := c.GetTrades(pairs, limit)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
 // PublicInfo is a single-return, panicking-on-error wrapper for
 // GetPublicInfo (calling GetInfo method of public V3 API, caching
 // result)
@@ -0,0 +1,61 @@
+package btce
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// fakeExchange serves public info for btc_usd and answers private
+// methods with handlers keyed by method name. A handler returns the
+// "return" value, or an error to be reported with success=0.
+type fakeExchange struct {
+	*httptest.Server
+	Public  map[string]interface{}
+	Methods map[string]func(url.Values) (interface{}, error)
+	Calls   []string
+}
+
+func newFakeExchange() *fakeExchange {
+	f := &fakeExchange{
+		Public: map[string]interface{}{
+			"info": PublicInfo{Pairs: map[string]PairInfo{
+				"btc_usd": {DecimalPlaces: 3, MinPrice: 0.1,
+					MaxPrice: 100000, MinAmount: 0.001, Fee: 0.2}}},
+		},
+		Methods: map[string]func(url.Values) (interface{}, error){},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeExchange) serve(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/3/") {
+		method := strings.Split(r.URL.Path, "/")[3]
+		json.NewEncoder(w).Encode(f.Public[method])
+		return
+	}
+	r.ParseForm()
+	method := r.Form.Get("method")
+	f.Calls = append(f.Calls, method)
+	handler, ok := f.Methods[method]
+	if !ok {
+		fmt.Fprintf(w, `{"success":0,"error":"unexpected method %v"}`, method)
+		return
+	}
+	result, err := handler(r.Form)
+	if err != nil {
+		json.NewEncoder(w).Encode(RemoteResult{Error: err.Error()})
+		return
+	}
+	data, _ := json.Marshal(result)
+	raw := json.RawMessage(data)
+	json.NewEncoder(w).Encode(RemoteResult{Success: 1, Return: &raw})
+}
+
+func (f *fakeExchange) client() *Client {
+	return &Client{URL: f.URL, Retries: &Retries{}}
+}
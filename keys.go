@@ -0,0 +1,169 @@
+package btce
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// KeyPassphraseEnv is the environment variable the default
+// KeyPassphrase takes a passphrase from.
+const KeyPassphraseEnv = "BTCE_KEY_PASSPHRASE"
+
+// KeyPassphrase provides a passphrase for an encrypted key file. By
+// default it's taken from $BTCE_KEY_PASSPHRASE; command-line tools
+// may replace it with a terminal prompt.
+var KeyPassphrase = func(fileName string) ([]byte, error) {
+	if passphrase, ok := os.LookupEnv(KeyPassphraseEnv); ok {
+		return []byte(passphrase), nil
+	}
+	return nil, fmt.Errorf("Key file %v is encrypted; set %v",
+		fileName, KeyPassphraseEnv)
+}
+
+// ReadKey loads an API key in JSON format from a file.  The value of
+// "nonce" may be present, but it's not necessary: server's "invalid
+// nonce" message provides corrected nonce value and are used for
+// retrying private API calls immediately.
+//
+// The file may be encrypted (see EncryptKey), with a passphrase
+// from KeyPassphrase. A warning is logged when a plaintext key file
+// is readable by group or others.
+func (c *Client) ReadKey(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	if IsEncrypted(data) {
+		passphrase, err := KeyPassphrase(fileName)
+		if err != nil {
+			return err
+		}
+		if data, err = Decrypt(data, passphrase); err != nil {
+			return err
+		}
+	} else if fi, err := os.Stat(fileName); err == nil && fi.Mode().Perm()&077 != 0 {
+		c.warnLogger().Warn("Plaintext key file is accessible by others",
+			"file", fileName, "mode", fi.Mode().Perm().String())
+	}
+	return c.decodeKey(data)
+}
+
+func (c *Client) decodeKey(data []byte) error {
+	auth := Auth{}
+	if err := json.Unmarshal(data, &auth); err != nil {
+		return err
+	}
+	if auth.Key == "" || auth.Secret == "" {
+		return errors.New("No key or secret in key data")
+	}
+	c.Auth = auth
+	return nil
+}
+
+// warnLogger is c.Logger, or the default slog logger: warnings
+// shouldn't be lost when no logger is configured.
+func (c *Client) warnLogger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+// ReadKeyEnv loads an API key from environment variables
+// <prefix>KEY, <prefix>SECRET and optional <prefix>NONCE, with
+// prefix defaulting to "BTCE_".
+func (c *Client) ReadKeyEnv(prefix string) error {
+	if prefix == "" {
+		prefix = "BTCE_"
+	}
+	auth := Auth{Key: os.Getenv(prefix + "KEY"), Secret: os.Getenv(prefix + "SECRET")}
+	if auth.Key == "" || auth.Secret == "" {
+		return fmt.Errorf("%vKEY and %vSECRET must be set", prefix, prefix)
+	}
+	if nonce := os.Getenv(prefix + "NONCE"); nonce != "" {
+		if _, err := fmt.Sscan(nonce, &auth.Nonce); err != nil {
+			return fmt.Errorf("Bad %vNONCE: %v", prefix, err)
+		}
+	}
+	c.Auth = auth
+	return nil
+}
+
+// ReadKeyCommand loads an API key in JSON format from the output of
+// a command, like a password manager CLI.
+func (c *Client) ReadKeyCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	data, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("Key command %v failed: %v", name, err)
+	}
+	return c.decodeKey(data)
+}
+
+// LoadKey loads an API key from a source, which is one of:
+//
+//	env          -- environment variables BTCE_KEY and BTCE_SECRET
+//	env:PREFIX_  -- environment variables PREFIX_KEY and PREFIX_SECRET
+//	cmd:COMMAND  -- output of a shell command
+//	FILENAME     -- a key file, plaintext or encrypted (see ReadKey)
+func (c *Client) LoadKey(source string) error {
+	switch {
+	case source == "env":
+		return c.ReadKeyEnv("")
+	case strings.HasPrefix(source, "env:"):
+		return c.ReadKeyEnv(strings.TrimPrefix(source, "env:"))
+	case strings.HasPrefix(source, "cmd:"):
+		return c.ReadKeyCommand("sh", "-c", strings.TrimPrefix(source, "cmd:"))
+	default:
+		return c.ReadKey(source)
+	}
+}
+
+// EncryptKey encrypts a plaintext key file into another one (which
+// may be the same), readable by the owner only.
+func EncryptKey(inFile, outFile string, passphrase []byte) error {
+	data, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+	if IsEncrypted(data) {
+		return errors.New("Key file is already encrypted: " + inFile)
+	}
+	if err := (&Client{}).decodeKey(data); err != nil {
+		return err
+	}
+	if data, err = Encrypt(data, passphrase); err != nil {
+		return err
+	}
+	return writePrivateFile(outFile, data)
+}
+
+// DecryptKey decrypts an encrypted key file into a plaintext one,
+// readable by the owner only.
+func DecryptKey(inFile, outFile string, passphrase []byte) error {
+	data, err := ioutil.ReadFile(inFile)
+	if err != nil {
+		return err
+	}
+	if data, err = Decrypt(data, passphrase); err != nil {
+		return err
+	}
+	return writePrivateFile(outFile, data)
+}
+
+// writePrivateFile replaces a file with mode 0600 data, through a
+// temporary file.
+func writePrivateFile(fileName string, data []byte) error {
+	tempFile := fileName + ".tmpnew"
+	if err := ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, fileName)
+}
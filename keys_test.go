@@ -0,0 +1,73 @@
+package btce
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	plain := filepath.Join(dir, "key.json")
+	ioutil.WriteFile(plain, []byte(`{"key":"K","secret":"S","nonce":5}`), 0644)
+
+	buf := &bytes.Buffer{}
+	c := &Client{Logger: slog.New(slog.NewTextHandler(buf, nil))}
+	if err := c.ReadKey(plain); err != nil || c.Auth.Key != "K" || c.Auth.Nonce != 5 {
+		t.Fatal(c.Auth, err)
+	}
+	if !strings.Contains(buf.String(), "accessible by others") {
+		t.Error("no warning for mode 0644:", buf.String())
+	}
+
+	ScryptN = 1 << 4
+	defer func() { ScryptN = 1 << 15 }()
+	enc := filepath.Join(dir, "key.enc.json")
+	if err := EncryptKey(plain, enc, []byte("pass")); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(enc); fi.Mode().Perm() != 0600 {
+		t.Error("encrypted key mode:", fi.Mode())
+	}
+	os.Setenv(KeyPassphraseEnv, "wrong")
+	defer os.Unsetenv(KeyPassphraseEnv)
+	if err := (&Client{}).ReadKey(enc); err != ErrPassphrase {
+		t.Error("expected ErrPassphrase, got", err)
+	}
+	os.Setenv(KeyPassphraseEnv, "pass")
+	c = &Client{}
+	if err := c.LoadKey(enc); err != nil || c.Auth.Secret != "S" {
+		t.Fatal(c.Auth, err)
+	}
+	if err := DecryptKey(enc, plain, []byte("pass")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeySources(t *testing.T) {
+	os.Setenv("TEST_BTCE_KEY", "K")
+	os.Setenv("TEST_BTCE_SECRET", "S")
+	os.Setenv("TEST_BTCE_NONCE", "7")
+	defer os.Unsetenv("TEST_BTCE_KEY")
+	defer os.Unsetenv("TEST_BTCE_SECRET")
+	defer os.Unsetenv("TEST_BTCE_NONCE")
+	c := &Client{}
+	if err := c.LoadKey("env:TEST_BTCE_"); err != nil || c.Auth != (Auth{"K", "S", 7}) {
+		t.Error(c.Auth, err)
+	}
+	c = &Client{}
+	if err := c.LoadKey(`cmd:echo '{"key":"CK","secret":"CS"}'`); err != nil || c.Auth.Key != "CK" {
+		t.Error(c.Auth, err)
+	}
+	if err := c.LoadKey("cmd:false"); err == nil {
+		t.Error("expected an error from a failing command")
+	}
+}
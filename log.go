@@ -0,0 +1,172 @@
+package btce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// discardLogger is used when no logger is configured.
+var discardLogger = slog.New(discardHandler{})
+
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// logger returns c.Logger, or a logger discarding everything.
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return discardLogger
+	}
+	return c.Logger
+}
+
+var pushLogger atomic.Pointer[slog.Logger]
+
+// SetPushLogger sets a logger for the push subsystem behind
+// FastDepth (subscriptions, depth events, resyncs and failures).
+// Nothing is logged by default.
+func SetPushLogger(l *slog.Logger) {
+	pushLogger.Store(l)
+}
+
+// PushObserver is notified of push subsystem activity.
+type PushObserver interface {
+	// PushEvent is called for each event received on a channel
+	PushEvent(channel string)
+	// PushResync is called when the book of a pair is (re)loaded
+	// from the server
+	PushResync(pair string)
+}
+
+type pushObserverBox struct{ PushObserver }
+
+var pushObserver atomic.Pointer[pushObserverBox]
+
+// SetPushObserver sets an observer for the push subsystem behind
+// FastDepth.
+func SetPushObserver(o PushObserver) {
+	pushObserver.Store(&pushObserverBox{o})
+}
+
+func pushObserved(f func(PushObserver)) {
+	if box := pushObserver.Load(); box != nil && box.PushObserver != nil {
+		f(box.PushObserver)
+	}
+}
+
+var pushFailureHandler atomic.Pointer[func(error)]
+
+// SetPushFailureHandler sets a function called when the push
+// subsystem behind FastDepth fails (on connection errors, bad events
+// or failed depth requests), right before the program crashes with a
+// panic, so it can still clean up: restore the terminal, for example.
+func SetPushFailureHandler(handler func(error)) {
+	pushFailureHandler.Store(&handler)
+}
+
+// pushRecover, deferred, passes a panic of the push subsystem to the
+// failure handler and goes on panicking.
+func pushRecover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if handler := pushFailureHandler.Load(); handler != nil && *handler != nil {
+		err, ok := r.(error)
+		if !ok {
+			err = fmt.Errorf("%v", r)
+		}
+		(*handler)(err)
+	}
+	panic(r)
+}
+
+func plog() *slog.Logger {
+	if l := pushLogger.Load(); l != nil {
+		return l
+	}
+	return discardLogger
+}
+
+// Redacted is what secret values are replaced with in logs.
+const Redacted = "[REDACTED]"
+
+// isSecret tells whether a parameter or header with this name must
+// not be logged.
+func isSecret(name string) bool {
+	switch strings.ToLower(name) {
+	case "key", "sign", "secret", "coupon":
+		return true
+	}
+	return false
+}
+
+// Params are private call parameters, logged with secrets redacted.
+type Params map[string]string
+
+// LogValue implements slog.LogValuer.
+func (p Params) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(p))
+	for name, value := range p {
+		if isSecret(name) {
+			value = Redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Return is a raw JSON call result, logged with values of secret
+// fields (like the code of a created coupon) redacted.
+type Return []byte
+
+// LogValue implements slog.LogValuer. Results that aren't valid JSON
+// are logged by size only.
+func (r Return) LogValue() slog.Value {
+	if len(r) == 0 {
+		return slog.StringValue("")
+	}
+	var v interface{}
+	if err := json.Unmarshal(r, &v); err != nil {
+		return slog.StringValue(fmt.Sprintf("[%d bytes]", len(r)))
+	}
+	data, err := json.Marshal(redact(v))
+	if err != nil {
+		return slog.StringValue(fmt.Sprintf("[%d bytes]", len(r)))
+	}
+	return slog.StringValue(string(data))
+}
+
+// redact replaces values of secret fields in decoded JSON.
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, value := range v {
+			if isSecret(name) {
+				v[name] = Redacted
+			} else {
+				v[name] = redact(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+	}
+	return v
+}
+
+// LogValue implements slog.LogValuer, so key and secret never get
+// into logs.
+func (a Auth) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("key", Redacted),
+		slog.String("secret", Redacted),
+		slog.Uint64("nonce", a.Nonce))
+}
@@ -0,0 +1,81 @@
+package btce
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLogRedaction(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	f.Methods["RedeemCoupon"] = func(url.Values) (interface{}, error) {
+		return RedeemCouponResult{}, nil
+	}
+	buf := &bytes.Buffer{}
+	c := f.client()
+	c.Auth = Auth{Key: "KEY-1", Secret: "SECRET-1"}
+	c.Logger = slog.New(slog.NewTextHandler(buf,
+		&slog.HandlerOptions{Level: slog.LevelDebug}))
+	c.Logger.Info("auth", "auth", c.Auth)
+	if err := c.Call(RedeemCouponParameters{Coupon: "COUPON-1"},
+		&RedeemCouponResult{}); err != nil {
+		t.Fatal(err)
+	}
+	f.Methods["CreateCoupon"] = func(url.Values) (interface{}, error) {
+		return map[string]interface{}{"coupon": "BTCE-USD-CODE-2",
+			"transID": 2, "funds": map[string]float64{"usd": 1}}, nil
+	}
+	created := CreateCouponResult{}
+	if err := c.Call(CreateCouponParameters{Currency: "USD", Amount: 1},
+		&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Coupon != "BTCE-USD-CODE-2" {
+		t.Errorf("Coupon %q, not decoded", created.Coupon)
+	}
+	out := buf.String()
+	for _, secret := range []string{"KEY-1", "SECRET-1", "COUPON-1", "BTCE-USD-CODE-2"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%v leaked into log:\n%v", secret, out)
+		}
+	}
+	for _, field := range []string{"method=RedeemCoupon", "method=CreateCoupon",
+		"nonce=", "attempt=1", "latency=", "transID", Redacted} {
+		if !strings.Contains(out, field) {
+			t.Errorf("no %v in log:\n%v", field, out)
+		}
+	}
+}
+
+func TestPushFailureHandler(t *testing.T) {
+	var handled error
+	SetPushFailureHandler(func(err error) { handled = err })
+	defer SetPushFailureHandler(nil)
+	defer func() {
+		if r := recover(); r == nil || handled == nil || handled.Error() != "Bad depth rate" {
+			t.Error("panic", r, "handled", handled)
+		}
+	}()
+	func() {
+		defer pushRecover()
+		pushFailed("Bad depth rate", errors.New("Bad depth rate"))
+	}()
+}
+
+func TestResolveReferenceLogs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := &Client{URL: "http://[bad", Logger: slog.New(slog.NewTextHandler(buf, nil))}
+	defer func() {
+		if recover() == nil {
+			t.Error("No panic on a bad URL")
+		}
+		if !strings.Contains(buf.String(), "Bad base URL") {
+			t.Errorf("Not logged: %q", buf.String())
+		}
+	}()
+	c.ResolveReference("/tapi")
+}
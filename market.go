@@ -0,0 +1,141 @@
+package btce
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MarketDepthLimit is the number of depth levels requested by
+// MarketBuy and MarketSell.
+var MarketDepthLimit uint = 2000
+
+// MarketResult describes an emulated market order.
+type MarketResult struct {
+	Trade         TradeResult
+	Rate          float64 // limit rate of the order placed
+	Filled        float64 // amount executed immediately
+	ExpectedPrice float64 // average rate expected from depth
+	ActualPrice   float64 // average rate derived from funds change
+}
+
+// MarketBuy buys amount of the pair's base currency at the best
+// available rates, emulating a market order with a limit order. See
+// MarketSell for details.
+func (c *Client) MarketBuy(pair string, amount float64, maxSlippage float64) (*MarketResult, error) {
+	return c.marketOrder("buy", pair, amount, maxSlippage)
+}
+
+// MarketSell sells amount of the pair's base currency at the best
+// available rates. Fresh depth is walked to find the rate at which
+// the whole amount would be filled; if it's worse than the best offer
+// by more than maxSlippage (a fraction: 0.01 is 1%), no order is
+// placed. Otherwise a limit order is placed at that rate, rounded to
+// the pair's decimal places and bounded by its min and max price.
+//
+// ActualPrice is derived from funds reported by getInfo before the
+// trade and by the trade itself; other activity on the account in
+// between makes it inaccurate. Like ExpectedPrice, it doesn't include
+// the fee.
+func (c *Client) MarketSell(pair string, amount float64, maxSlippage float64) (*MarketResult, error) {
+	return c.marketOrder("sell", pair, amount, maxSlippage)
+}
+
+// fillRate walks offers until amount is covered, returning the
+// rate of the last offer used and the average rate.
+func fillRate(amount float64, offers []Offer) (float64, float64, error) {
+	cost := sumDepth(amount, offers)
+	if math.IsNaN(cost) {
+		return 0, 0, errors.New("Not enough depth to fill the amount")
+	}
+	rate := 0.0
+	for left := amount; left > 0 && len(offers) > 0; offers = offers[1:] {
+		rate = offers[0].Rate()
+		left -= offers[0].Amount()
+	}
+	return rate, cost / amount, nil
+}
+
+// roundRate rounds a rate to the pair's decimal places, up for buy
+// orders and down for sell orders, so the order still covers the
+// rate it was computed for.
+func roundRate(kind string, rate float64, info PairInfo) float64 {
+	scale := math.Pow(10, float64(info.DecimalPlaces))
+	if kind == "buy" {
+		rate = math.Ceil(rate*scale-1e-6) / scale
+	} else {
+		rate = math.Floor(rate*scale+1e-6) / scale
+	}
+	if info.MaxPrice > 0 && rate > info.MaxPrice {
+		rate = info.MaxPrice
+	}
+	if rate < info.MinPrice {
+		rate = info.MinPrice
+	}
+	return rate
+}
+
+func (c *Client) marketOrder(kind string, pair string, amount float64, maxSlippage float64) (*MarketResult, error) {
+	info, err := c.GetPublicInfo()
+	if err != nil {
+		return nil, err
+	}
+	pairInfo, ok := info.Pairs[pair]
+	if !ok {
+		return nil, errors.New("Unknown pair: " + pair)
+	}
+	if amount < pairInfo.MinAmount {
+		return nil, fmt.Errorf("Amount %v is below minimum %v for %v",
+			amount, pairInfo.MinAmount, pair)
+	}
+	depths, err := c.GetDepth([]string{pair}, MarketDepthLimit)
+	if err != nil {
+		return nil, err
+	}
+	offers := depths[pair].Bids
+	if kind == "buy" {
+		offers = depths[pair].Asks
+	}
+	worst, average, err := fillRate(amount, offers)
+	if err != nil {
+		return nil, err
+	}
+	best := offers[0].Rate()
+	slippage := 1 - worst/best
+	if kind == "buy" {
+		slippage = worst/best - 1
+	}
+	if slippage > maxSlippage {
+		return nil, fmt.Errorf("Slippage %.4f%% exceeds %.4f%% (best rate %v, required %v)",
+			slippage*100, maxSlippage*100, best, worst)
+	}
+	result := &MarketResult{Rate: roundRate(kind, worst, pairInfo),
+		ExpectedPrice: average}
+
+	before := GetInfoResult{}
+	if err = c.Call(GetInfoParameters{}, &before); err != nil {
+		return nil, err
+	}
+	err = c.Call(TradeParameters{Pair: pair, Type: kind, Rate: result.Rate,
+		Amount: amount}, &result.Trade)
+	if err != nil {
+		return nil, err
+	}
+	result.Filled = amount
+	if result.Trade.OrderId != 0 {
+		result.Filled = amount - result.Trade.Remains
+	}
+	if result.Filled > 0 && result.Trade.Funds != nil {
+		quote := pair[strings.LastIndex(pair, "_")+1:]
+		change := result.Trade.Funds[quote] - before.Funds[quote]
+		if kind == "buy" {
+			spent := -change - result.Trade.Remains*result.Rate
+			result.ActualPrice = spent / result.Filled
+		} else {
+			qfee := (100 - pairInfo.Fee) / 100
+			result.ActualPrice = change / qfee / result.Filled
+		}
+	}
+	return result, nil
+}
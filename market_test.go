@@ -0,0 +1,38 @@
+package btce
+
+import (
+	"math"
+	"net/url"
+	"testing"
+)
+
+func TestMarketBuy(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	f.Public["depth"] = map[string]DepthInfo{"btc_usd": {
+		Asks: []Offer{{100, 0.5}, {101.0004, 1}},
+		Bids: []Offer{{99, 1}}}}
+	f.Methods["getInfo"] = func(url.Values) (interface{}, error) {
+		return GetInfoResult{Funds: map[string]float64{"usd": 1000}}, nil
+	}
+	var rate string
+	f.Methods["Trade"] = func(v url.Values) (interface{}, error) {
+		rate = v.Get("rate")
+		return TradeResult{Received: 1,
+			Funds: map[string]float64{"usd": 1000 - 100.5}}, nil
+	}
+	c := f.client()
+	if _, err := c.MarketBuy("btc_usd", 1, 0.001); err == nil {
+		t.Error("slippage limit ignored")
+	}
+	r, err := c.MarketBuy("btc_usd", 1, 0.02)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != "101.001" {
+		t.Error("unexpected rate:", rate)
+	}
+	if math.Abs(r.ExpectedPrice-100.5002) > 1e-9 || math.Abs(r.ActualPrice-100.5) > 1e-9 {
+		t.Errorf("%+v", r)
+	}
+}
@@ -0,0 +1,281 @@
+// Package metrics collects API call and push stream statistics of
+// btce clients, and serves them in Prometheus text exposition format
+// (no Prometheus libraries needed).
+//
+//	m := metrics.New()
+//	m.Attach(client)
+//	m.AttachPush()
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// DefaultBuckets are upper bounds (in seconds) of latency histogram
+// buckets.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Results of API calls, as in the "result" label of requests.
+const (
+	ResultOK          = "ok"           // success
+	ResultRemoteError = "remote_error" // private call answered with success=0
+	ResultError       = "error"        // HTTP or decoding failure
+)
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Registry keeps metrics. It implements btce.CallObserver and
+// btce.PushObserver.
+type Registry struct {
+	Buckets []float64        // DefaultBuckets if nil
+	Now     func() time.Time // time.Now if nil
+
+	mu        sync.Mutex
+	requests  map[[3]string]uint64 // method, kind, result
+	latency   map[string]*histogram
+	retries   map[string]uint64
+	nonces    map[string]uint64
+	statuses  map[[2]string]uint64 // method, status class
+	events    map[string]uint64
+	resyncs   map[string]uint64
+	lastEvent map[string]time.Time
+}
+
+// New returns an empty registry.
+func New() *Registry {
+	return &Registry{
+		requests:  map[[3]string]uint64{},
+		latency:   map[string]*histogram{},
+		retries:   map[string]uint64{},
+		nonces:    map[string]uint64{},
+		statuses:  map[[2]string]uint64{},
+		events:    map[string]uint64{},
+		resyncs:   map[string]uint64{},
+		lastEvent: map[string]time.Time{},
+	}
+}
+
+// Attach makes the registry collect metrics of a client's calls.
+func (r *Registry) Attach(c *btce.Client) {
+	c.Use(r.Middleware)
+	c.Observer = r
+}
+
+// AttachPush makes the registry collect metrics of the push
+// subsystem (see btce.FastDepth).
+func (r *Registry) AttachPush() {
+	btce.SetPushObserver(r)
+}
+
+// Middleware counts calls and measures their latency.
+func (r *Registry) Middleware(next btce.Invoker) btce.Invoker {
+	return func(inv *btce.Invocation) error {
+		err := next(inv)
+		r.Request(inv, err)
+		return err
+	}
+}
+
+// Request records a finished invocation.
+func (r *Registry) Request(inv *btce.Invocation, err error) {
+	kind, result := "public", ResultOK
+	if inv.Private {
+		kind = "private"
+	}
+	if err != nil {
+		result = ResultError
+	} else if inv.Private {
+		var rr struct{ Success uint }
+		if json.Unmarshal(inv.Response, &rr) != nil || rr.Success == 0 {
+			result = ResultRemoteError
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[[3]string{inv.Method, kind, result}]++
+	h := r.latency[inv.Method]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(r.buckets()))}
+		r.latency[inv.Method] = h
+	}
+	seconds := inv.Duration.Seconds()
+	for i, bound := range r.buckets() {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// HTTPStatus implements btce.CallObserver.
+func (r *Registry) HTTPStatus(method string, status int) {
+	class := "none"
+	if status > 0 {
+		class = fmt.Sprintf("%dxx", status/100)
+	}
+	r.mu.Lock()
+	r.statuses[[2]string{method, class}]++
+	r.mu.Unlock()
+}
+
+// Retry implements btce.CallObserver.
+func (r *Registry) Retry(method string) {
+	r.mu.Lock()
+	r.retries[method]++
+	r.mu.Unlock()
+}
+
+// NonceCorrection implements btce.CallObserver.
+func (r *Registry) NonceCorrection(method string) {
+	r.mu.Lock()
+	r.nonces[method]++
+	r.mu.Unlock()
+}
+
+// PushEvent implements btce.PushObserver.
+func (r *Registry) PushEvent(channel string) {
+	r.mu.Lock()
+	r.events[channel]++
+	r.lastEvent[channel] = r.now()
+	r.mu.Unlock()
+}
+
+// PushResync implements btce.PushObserver. Resync also counts as
+// fresh data for staleness of the pair's depth channel.
+func (r *Registry) PushResync(pair string) {
+	r.mu.Lock()
+	r.resyncs[pair]++
+	r.lastEvent[pair+".depth"] = r.now()
+	r.mu.Unlock()
+}
+
+func (r *Registry) buckets() []float64 {
+	if r.Buckets == nil {
+		return DefaultBuckets
+	}
+	return r.Buckets
+}
+
+func (r *Registry) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
+
+// ServeHTTP writes all metrics in Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteTo(w)
+}
+
+// WriteTo writes all metrics in Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := &printer{}
+
+	p.header("btce_requests_total", "counter", "API calls by method, kind and result.")
+	for _, k := range sortedKeys(r.requests, func(k [3]string) string { return strings.Join(k[:], "\x00") }) {
+		p.sample("btce_requests_total", labels("method", k[0], "kind", k[1], "result", k[2]), float64(r.requests[k]))
+	}
+
+	p.header("btce_request_duration_seconds", "histogram", "API call latency, including retries.")
+	for _, method := range sortedKeys(r.latency, ident) {
+		h := r.latency[method]
+		cumulative := uint64(0)
+		for i, bound := range r.buckets() {
+			cumulative += h.counts[i]
+			p.sample("btce_request_duration_seconds_bucket",
+				labels("method", method, "le", formatFloat(bound)), float64(cumulative))
+		}
+		p.sample("btce_request_duration_seconds_bucket",
+			labels("method", method, "le", "+Inf"), float64(h.count))
+		p.sample("btce_request_duration_seconds_sum", labels("method", method), h.sum)
+		p.sample("btce_request_duration_seconds_count", labels("method", method), float64(h.count))
+	}
+
+	p.counters("btce_retries_total", "HTTP request retries after failures.", "method", r.retries)
+	p.counters("btce_nonce_corrections_total", "Private calls repeated with nonce corrected by the server.", "method", r.nonces)
+
+	p.header("btce_http_responses_total", "counter", "HTTP responses by status class (none: no response).")
+	for _, k := range sortedKeys(r.statuses, func(k [2]string) string { return k[0] + "\x00" + k[1] }) {
+		p.sample("btce_http_responses_total", labels("method", k[0], "class", k[1]), float64(r.statuses[k]))
+	}
+
+	p.counters("btce_push_events_total", "Push events received.", "channel", r.events)
+	p.counters("btce_push_resyncs_total", "Depth book reloads from the server.", "pair", r.resyncs)
+
+	p.header("btce_push_staleness_seconds", "gauge", "Time since the last update of a push channel.")
+	now := r.now()
+	for _, channel := range sortedKeys(r.lastEvent, ident) {
+		p.sample("btce_push_staleness_seconds", labels("channel", channel),
+			now.Sub(r.lastEvent[channel]).Seconds())
+	}
+
+	n, err := io.WriteString(w, p.String())
+	return int64(n), err
+}
+
+type printer struct{ strings.Builder }
+
+func (p *printer) header(name, typ, help string) {
+	fmt.Fprintf(p, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, typ)
+}
+
+func (p *printer) sample(name, labels string, value float64) {
+	fmt.Fprintf(p, "%v{%v} %v\n", name, labels, formatFloat(value))
+}
+
+func (p *printer) counters(name, help, label string, m map[string]uint64) {
+	p.header(name, "counter", help)
+	for _, k := range sortedKeys(m, ident) {
+		p.sample(name, labels(label, k), float64(m[k]))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats name/value pairs as a label set.
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+labelEscaper.Replace(pairs[i+1])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprint(v)
+}
+
+func ident(s string) string { return s }
+
+func sortedKeys[K comparable, V any](m map[K]V, key func(K) string) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return key(keys[i]) < key(keys[j]) })
+	return keys
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+func TestMetrics(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/3/info") {
+			fmt.Fprint(w, `{"pairs":{"btc_usd":{"decimal_places":3}}}`)
+			return
+		}
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			fmt.Fprint(w, `{"success":0,"error":"invalid nonce parameter; on key:5, you sent:0"}`)
+		default:
+			fmt.Fprint(w, `{"success":1,"return":{"funds":{"usd":1}}}`)
+		}
+	}))
+	defer server.Close()
+
+	m := New()
+	now := time.Unix(1000, 0)
+	m.Now = func() time.Time { return now }
+	c := &btce.Client{URL: server.URL, Retries: &btce.Retries{NonceCorrection: 1, GeneralError: 1}}
+	m.Attach(c)
+	if err := c.Call(btce.GetInfoParameters{}, &btce.GetInfoResult{}); err != nil {
+		t.Fatal(err)
+	}
+	m.PushResync("btc_usd")
+	m.PushEvent("btc_usd.depth")
+	now = now.Add(3 * time.Second)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	for _, line := range []string{
+		`btce_requests_total{method="info",kind="public",result="ok"} 1`,
+		`btce_requests_total{method="getInfo",kind="private",result="ok"} 1`,
+		`btce_request_duration_seconds_count{method="getInfo"} 1`,
+		`btce_request_duration_seconds_bucket{method="getInfo",le="+Inf"} 1`,
+		`btce_retries_total{method="getInfo"} 1`,
+		`btce_nonce_corrections_total{method="getInfo"} 1`,
+		`btce_http_responses_total{method="getInfo",class="2xx"} 2`,
+		`btce_http_responses_total{method="getInfo",class="5xx"} 1`,
+		`btce_push_events_total{channel="btc_usd.depth"} 1`,
+		`btce_push_resyncs_total{pair="btc_usd"} 1`,
+		`btce_push_staleness_seconds{channel="btc_usd.depth"} 3`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("no %v in:\n%v", line, out)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	if l := labels("a", `x"y\z`+"\n"); l != `a="x\"y\\z\n"` {
+		t.Error(l)
+	}
+}
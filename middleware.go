@@ -0,0 +1,81 @@
+package btce
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// Invocation is a single public or private API call, as seen by
+// middleware. Request fields are set by the caller; Response and
+// Duration are filled by the innermost invoker.
+type Invocation struct {
+	Method  string
+	Private bool
+
+	// Pairs and Values are parameters of a public call
+	Pairs  []string
+	Values *url.Values
+
+	// Params are parameters of a private call, including "method"
+	// but not "nonce"
+	Params map[string]string
+
+	// Response is the raw JSON answer. For a private call it's the
+	// whole RemoteResult, so a remote failure (success=0) is not an
+	// invoker error.
+	Response []byte
+	Duration time.Duration
+}
+
+// Invoker performs an API call, filling inv.Response on success.
+type Invoker func(inv *Invocation) error
+
+// Middleware wraps an invoker, to look at (or change) invocations
+// before and after the call, or to answer them without calling next.
+type Middleware func(next Invoker) Invoker
+
+// Use adds middleware around every API call of the client, public
+// and private. Middleware added later is called earlier (it's the
+// outermost one).
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+// invoke passes inv through the middleware chain to the backend or
+// the server.
+func (c *Client) invoke(inv *Invocation) error {
+	invoker := Invoker(c.call)
+	for _, mw := range c.middleware {
+		invoker = mw(invoker)
+	}
+	return invoker(inv)
+}
+
+// call is the innermost invoker.
+func (c *Client) call(inv *Invocation) error {
+	start := time.Now()
+	defer func() { inv.Duration = time.Since(start) }()
+	var err error
+	switch {
+	case !inv.Private:
+		inv.Response, err = c.publicCall(inv.Method, inv.Pairs, inv.Values)
+	case c.Backend != nil:
+		var result *RemoteResult
+		result, err = c.Backend.RemoteCall(inv.Params)
+		if err == nil {
+			inv.Response, err = json.Marshal(result)
+		}
+	default:
+		inv.Response, err = c.remoteCallRetry(inv.Params)
+	}
+	if err != nil {
+		c.logger().Warn("API call failed", "method", inv.Method,
+			"private", inv.Private, "latency", time.Since(start),
+			"error", err)
+	} else if !inv.Private {
+		c.logger().Debug("Public API call", "method", inv.Method,
+			"pairs", inv.Pairs, "latency", time.Since(start))
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package btce
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	f.Methods["getInfo"] = func(url.Values) (interface{}, error) {
+		return GetInfoResult{Funds: map[string]float64{"usd": 100}}, nil
+	}
+	c := f.client()
+	var seen []string
+	c.Use(func(next Invoker) Invoker {
+		return func(inv *Invocation) error {
+			err := next(inv)
+			if inv.Private {
+				seen = append(seen, inv.Params["method"]+":"+string(inv.Response[:12]))
+			} else {
+				seen = append(seen, inv.Method)
+			}
+			return err
+		}
+	})
+	if err := c.Call(GetInfoParameters{}, &GetInfoResult{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != "info" || seen[1] != `getInfo:{"success":1` {
+		t.Error("unexpected invocations:", seen)
+	}
+
+	// outermost middleware can answer without calling the server
+	c.Use(func(next Invoker) Invoker {
+		return func(inv *Invocation) error {
+			if inv.Private {
+				return errors.New("Injected")
+			}
+			return next(inv)
+		}
+	})
+	if err := c.Call(GetInfoParameters{}, &GetInfoResult{}); err == nil || err.Error() != "Injected" {
+		t.Error("expected injected error, got", err)
+	}
+	if len(f.Calls) != 1 || seen[len(seen)-1] != "info" {
+		t.Error("unexpected calls:", f.Calls, seen)
+	}
+}
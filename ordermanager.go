@@ -0,0 +1,311 @@
+package btce
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// OrderEventType tells what happened to a tracked order.
+type OrderEventType int
+
+const (
+	OrderPlaced OrderEventType = iota
+	OrderPartiallyFilled
+	OrderFilled
+	OrderCancelled
+)
+
+func (t OrderEventType) String() string {
+	switch t {
+	case OrderPlaced:
+		return "placed"
+	case OrderPartiallyFilled:
+		return "partially filled"
+	case OrderFilled:
+		return "filled"
+	case OrderCancelled:
+		return "cancelled"
+	}
+	return fmt.Sprint("OrderEventType(", int(t), ")")
+}
+
+// TrackedOrder is the last known state of an order managed by
+// OrderManager.
+type TrackedOrder struct {
+	Id          uint64
+	Pair        string
+	Type        string
+	Rate        float64
+	StartAmount float64
+	Amount      float64 // remaining amount
+	Status      uint
+}
+
+// Filled returns the amount executed so far.
+func (o TrackedOrder) Filled() float64 { return o.StartAmount - o.Amount }
+
+// OrderEvent is delivered to OrderManager handlers. Filled is the
+// amount executed since the previous event for the same order.
+type OrderEvent struct {
+	Type   OrderEventType
+	Order  TrackedOrder
+	Filled float64
+}
+
+// OrderStore keeps tracked orders across restarts.
+type OrderStore interface {
+	Load() (map[uint64]TrackedOrder, error)
+	Save(map[uint64]TrackedOrder) error
+}
+
+// MemoryOrderStore is an OrderStore that doesn't persist anything.
+type MemoryOrderStore struct{}
+
+func (MemoryOrderStore) Load() (map[uint64]TrackedOrder, error) {
+	return map[uint64]TrackedOrder{}, nil
+}
+
+func (MemoryOrderStore) Save(map[uint64]TrackedOrder) error { return nil }
+
+// FileOrderStore is an OrderStore keeping orders in a JSON file with
+// the given name. The file is replaced atomically on each save; a
+// missing file means no orders.
+type FileOrderStore string
+
+func (f FileOrderStore) Load() (map[uint64]TrackedOrder, error) {
+	orders := map[uint64]TrackedOrder{}
+	data, err := ioutil.ReadFile(string(f))
+	if os.IsNotExist(err) {
+		return orders, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return orders, json.Unmarshal(data, &orders)
+}
+
+func (f FileOrderStore) Save(orders map[uint64]TrackedOrder) error {
+	data, err := json.Marshal(orders)
+	if err != nil {
+		return err
+	}
+	tempFile := string(f) + ".tmpnew"
+	if err = ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, string(f))
+}
+
+// OrderManager places orders and follows them until they're filled
+// or cancelled, detecting changes by polling ActiveOrders and, for
+// orders gone from there, OrderInfo.
+//
+// Events are delivered synchronously to handlers from Place, Cancel
+// and Poll.
+type OrderManager struct {
+	Client   *Client
+	Store    OrderStore
+	mutex    sync.Mutex
+	orders   map[uint64]TrackedOrder
+	handlers []func(OrderEvent)
+}
+
+// NewOrderManager creates an OrderManager, loading previously tracked
+// orders from store.
+func NewOrderManager(c *Client, store OrderStore) (*OrderManager, error) {
+	orders, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &OrderManager{Client: c, Store: store, orders: orders}, nil
+}
+
+// OnEvent registers an event handler.
+func (m *OrderManager) OnEvent(handler func(OrderEvent)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.handlers = append(m.handlers, handler)
+}
+
+func (m *OrderManager) emit(events []OrderEvent) {
+	m.mutex.Lock()
+	handlers := m.handlers
+	m.mutex.Unlock()
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// Orders returns a snapshot of tracked orders.
+func (m *OrderManager) Orders() map[uint64]TrackedOrder {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	result := make(map[uint64]TrackedOrder, len(m.orders))
+	for id, order := range m.orders {
+		result[id] = order
+	}
+	return result
+}
+
+// save persists tracked orders; called with the mutex held.
+func (m *OrderManager) save() error {
+	return m.Store.Save(m.orders)
+}
+
+// Place places an order with Trade and starts tracking it. An order
+// executed completely on placement is reported as placed and filled
+// at once, with zero Id, and not tracked.
+func (m *OrderManager) Place(p TradeParameters) (TradeResult, error) {
+	result := TradeResult{}
+	if err := m.Client.Call(p, &result); err != nil {
+		return result, err
+	}
+	order := TrackedOrder{Id: result.OrderId, Pair: p.Pair, Type: p.Type,
+		Rate: p.Rate, StartAmount: p.Amount, Amount: result.Remains}
+	events := []OrderEvent{{Type: OrderPlaced, Order: order}}
+	if result.OrderId == 0 {
+		order.Amount, order.Status = 0, 1
+		events = append(events, OrderEvent{Type: OrderFilled,
+			Order: order, Filled: p.Amount})
+		m.emit(events)
+		return result, nil
+	}
+	if order.Filled() > 0 {
+		events = append(events, OrderEvent{Type: OrderPartiallyFilled,
+			Order: order, Filled: order.Filled()})
+	}
+	m.mutex.Lock()
+	m.orders[order.Id] = order
+	err := m.save()
+	m.mutex.Unlock()
+	m.emit(events)
+	return result, err
+}
+
+// Track starts tracking an existing order, given its id.
+func (m *OrderManager) Track(id uint64) error {
+	info := OrderInfoResult{}
+	if err := m.Client.Call(OrderInfoParameters{OrderId: id}, &info); err != nil {
+		return err
+	}
+	i, ok := info[id]
+	if !ok {
+		return fmt.Errorf("Order #%v not found", id)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.orders[id] = TrackedOrder{Id: id, Pair: i.Pair, Type: i.Type,
+		Rate: i.Rate, StartAmount: i.StartAmount, Amount: i.Amount,
+		Status: i.Status}
+	return m.save()
+}
+
+// Forget stops tracking an order without touching it.
+func (m *OrderManager) Forget(id uint64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.orders, id)
+	return m.save()
+}
+
+// Cancel cancels a tracked order, then resolves its final state with
+// OrderInfo, reporting fills that happened before the cancellation.
+func (m *OrderManager) Cancel(id uint64) (CancelOrderResult, error) {
+	result := CancelOrderResult{}
+	if err := m.Client.Call(CancelOrderParameters{OrderId: id}, &result); err != nil {
+		return result, err
+	}
+	return result, m.resolve(id)
+}
+
+// resolve queries OrderInfo for an order no longer active, emitting
+// final events and forgetting it. Orders still reported as active are
+// left alone.
+func (m *OrderManager) resolve(id uint64) error {
+	info := OrderInfoResult{}
+	if err := m.Client.Call(OrderInfoParameters{OrderId: id}, &info); err != nil {
+		return err
+	}
+	i, ok := info[id]
+	if !ok || i.Status == 0 {
+		return nil
+	}
+	m.mutex.Lock()
+	order, tracked := m.orders[id]
+	if !tracked {
+		m.mutex.Unlock()
+		return nil
+	}
+	events := []OrderEvent{}
+	filled := order.Amount - i.Amount
+	order.Amount, order.Status = i.Amount, i.Status
+	switch i.Status {
+	case 1:
+		filled, order.Amount = filled+order.Amount, 0
+		events = append(events, OrderEvent{Type: OrderFilled,
+			Order: order, Filled: filled})
+	default:
+		if filled > 0 {
+			events = append(events, OrderEvent{Type: OrderPartiallyFilled,
+				Order: order, Filled: filled})
+		}
+		events = append(events, OrderEvent{Type: OrderCancelled, Order: order})
+	}
+	delete(m.orders, id)
+	err := m.save()
+	m.mutex.Unlock()
+	m.emit(events)
+	return err
+}
+
+// Poll checks tracked orders against ActiveOrders, emitting events
+// for partial fills and resolving orders that are gone. It should be
+// called periodically.
+func (m *OrderManager) Poll() error {
+	if len(m.Orders()) == 0 {
+		return nil
+	}
+	active := ActiveOrdersResult{}
+	if err := m.Client.Call(ActiveOrdersParameters{}, &active); err != nil {
+		return err
+	}
+	gone := []uint64{}
+	events := []OrderEvent{}
+	m.mutex.Lock()
+	changed := false
+	for id, order := range m.orders {
+		a, ok := active[id]
+		if !ok {
+			gone = append(gone, id)
+			continue
+		}
+		if a.Amount < order.Amount {
+			filled := order.Amount - a.Amount
+			order.Amount = a.Amount
+			m.orders[id] = order
+			changed = true
+			events = append(events, OrderEvent{Type: OrderPartiallyFilled,
+				Order: order, Filled: filled})
+		}
+	}
+	var err error
+	if changed {
+		err = m.save()
+	}
+	m.mutex.Unlock()
+	m.emit(events)
+	if err != nil {
+		return err
+	}
+	for _, id := range gone {
+		if err := m.resolve(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
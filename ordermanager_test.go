@@ -0,0 +1,58 @@
+package btce
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestOrderManagerPoll(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	remains := 1.0
+	status := uint(0)
+	f.Methods["Trade"] = func(url.Values) (interface{}, error) {
+		return TradeResult{OrderId: 5, Remains: remains}, nil
+	}
+	f.Methods["ActiveOrders"] = func(url.Values) (interface{}, error) {
+		if status != 0 {
+			return ActiveOrdersResult{}, nil
+		}
+		return ActiveOrdersResult{5: {Pair: "btc_usd", Type: "buy",
+			Rate: 100, Amount: remains}}, nil
+	}
+	f.Methods["OrderInfo"] = func(url.Values) (interface{}, error) {
+		return OrderInfoResult{5: {Pair: "btc_usd", Type: "buy",
+			Rate: 100, StartAmount: 1, Amount: remains, Status: status}}, nil
+	}
+
+	m, err := NewOrderManager(f.client(), MemoryOrderStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []OrderEvent{}
+	m.OnEvent(func(e OrderEvent) { events = append(events, e) })
+	if _, err = m.Place(TradeParameters{Pair: "btc_usd", Type: "buy",
+		Rate: 100, Amount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	remains = 0.25
+	if err = m.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	remains, status = 0.25, 3
+	if err = m.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []OrderEventType{OrderPlaced, OrderPartiallyFilled, OrderCancelled}
+	if len(events) != len(expected) {
+		t.Fatalf("events: %+v", events)
+	}
+	for i, e := range events {
+		if e.Type != expected[i] {
+			t.Errorf("event %v: %v, expected %v", i, e.Type, expected[i])
+		}
+	}
+	if events[1].Filled != 0.75 || len(m.Orders()) != 0 {
+		t.Errorf("filled %v, still tracking %v", events[1].Filled, m.Orders())
+	}
+}
@@ -0,0 +1,71 @@
+package paper
+
+import (
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Market provides public data for a paper exchange: pair info,
+// tickers, depth and recent trades (newest first, like the "trades"
+// method), and the current time.
+type Market interface {
+	Info() (*btce.PublicInfo, error)
+	Ticker(pair string) (btce.TickerInfo, error)
+	Depth(pair string) (btce.DepthInfo, error)
+	Trades(pair string) ([]btce.TradeInfo, error)
+	Now() time.Time
+}
+
+// LiveMarket is a Market reading real public data with a client.
+type LiveMarket struct {
+	Client     *btce.Client
+	DepthLimit uint // depth levels requested; 150 if zero
+	TradeLimit uint // trades requested; 150 if zero
+	info       *btce.PublicInfo
+}
+
+func orDefault(limit uint) uint {
+	if limit == 0 {
+		return 150
+	}
+	return limit
+}
+
+// Info returns public info, fetched once and cached.
+func (m *LiveMarket) Info() (*btce.PublicInfo, error) {
+	if m.info == nil {
+		info, err := m.Client.GetPublicInfo()
+		if err != nil {
+			return nil, err
+		}
+		m.info = info
+	}
+	return m.info, nil
+}
+
+func (m *LiveMarket) Ticker(pair string) (btce.TickerInfo, error) {
+	tickers, err := m.Client.GetTicker([]string{pair})
+	if err != nil {
+		return btce.TickerInfo{}, err
+	}
+	return tickers[pair], nil
+}
+
+func (m *LiveMarket) Depth(pair string) (btce.DepthInfo, error) {
+	depths, err := m.Client.GetDepth([]string{pair}, orDefault(m.DepthLimit))
+	if err != nil {
+		return btce.DepthInfo{}, err
+	}
+	return depths[pair], nil
+}
+
+func (m *LiveMarket) Trades(pair string) ([]btce.TradeInfo, error) {
+	trades, err := m.Client.GetTrades([]string{pair}, orDefault(m.TradeLimit))
+	if err != nil {
+		return nil, err
+	}
+	return trades[pair], nil
+}
+
+func (m *LiveMarket) Now() time.Time { return time.Now() }
@@ -0,0 +1,521 @@
+// Package paper implements paper trading: a btce.Backend keeping
+// simulated balances and orders, filled against real (or replayed)
+// market data, so strategies can run unchanged without risking
+// funds.
+//
+//	market := &paper.LiveMarket{Client: &btce.Client{}}
+//	funds, _ := paper.LoadFunds("funds.json")
+//	client := &btce.Client{Backend: paper.New(market, funds)}
+//
+// Public methods of such a client answer from the market. Private
+// methods getInfo, Trade, ActiveOrders, OrderInfo, CancelOrder,
+// TradeHistory and TransHistory answer from the simulated state; the
+// rest fail.
+//
+// A new order is filled immediately against current depth, as far
+// as its rate permits; the rest waits and is filled at its own rate
+// by public trades crossing it, or when the depth crosses it. Fills
+// are charged the pair's fee. Liquidity used by simulated orders is
+// not removed from the market.
+package paper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// epsilon is the amount considered zero for remaining order amounts.
+const epsilon = 1e-9
+
+// Order is a simulated order.
+type Order struct {
+	btce.OrderInfo
+	Id uint64
+}
+
+// State is the complete state of a paper exchange, which can be
+// saved and restored.
+type State struct {
+	Funds       map[string]float64
+	Orders      map[uint64]*Order
+	Trades      btce.TradeHistoryResult
+	NextOrderId uint64
+	NextTradeId uint64
+	LastTradeId map[string]uint64 // newest public trade seen per pair
+}
+
+// Exchange is a paper-trading btce.Backend.
+type Exchange struct {
+	Market Market
+	State  State
+	mutex  sync.Mutex
+}
+
+// New creates a paper exchange with initial funds.
+func New(market Market, funds map[string]float64) *Exchange {
+	e := &Exchange{Market: market}
+	e.State = State{
+		Funds:       map[string]float64{},
+		Orders:      map[uint64]*Order{},
+		Trades:      btce.TradeHistoryResult{},
+		NextOrderId: 1,
+		NextTradeId: 1,
+		LastTradeId: map[string]uint64{},
+	}
+	for currency, amount := range funds {
+		e.State.Funds[currency] = amount
+	}
+	return e
+}
+
+// LoadFunds reads initial funds from a JSON file, like
+// {"usd": 1000, "btc": 0.5}.
+func LoadFunds(fileName string) (map[string]float64, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	funds := map[string]float64{}
+	return funds, json.Unmarshal(data, &funds)
+}
+
+// AccountFunds returns real funds of an account (given a client with
+// a key), to start paper trading from the same position.
+func AccountFunds(c *btce.Client) (map[string]float64, error) {
+	info := btce.GetInfoResult{}
+	if err := c.Call(btce.GetInfoParameters{}, &info); err != nil {
+		return nil, err
+	}
+	return info.Funds, nil
+}
+
+// Load restores a paper exchange saved with Save.
+func Load(market Market, fileName string) (*Exchange, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	e := New(market, nil)
+	return e, json.Unmarshal(data, &e.State)
+}
+
+// Save writes the exchange state to a file.
+func (e *Exchange) Save(fileName string) error {
+	e.mutex.Lock()
+	data, err := json.Marshal(e.State)
+	e.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	tempFile := fileName + ".tmpnew"
+	if err = ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, fileName)
+}
+
+// PublicCall answers public methods info, ticker, depth and trades
+// from the market.
+func (e *Exchange) PublicCall(method string, pairs []string, values *url.Values) ([]byte, error) {
+	limit := 0
+	if values != nil {
+		limit, _ = strconv.Atoi(values.Get("limit"))
+	}
+	result := map[string]interface{}{}
+	if method == "info" {
+		info, err := e.Market.Info()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(info)
+	}
+	for _, pair := range pairs {
+		var v interface{}
+		var err error
+		switch method {
+		case "ticker":
+			v, err = e.Market.Ticker(pair)
+		case "depth":
+			var d btce.DepthInfo
+			d, err = e.Market.Depth(pair)
+			if limit > 0 && len(d.Asks) > limit {
+				d.Asks = d.Asks[:limit]
+			}
+			if limit > 0 && len(d.Bids) > limit {
+				d.Bids = d.Bids[:limit]
+			}
+			v = d
+		case "trades":
+			var t []btce.TradeInfo
+			t, err = e.Market.Trades(pair)
+			if limit > 0 && len(t) > limit {
+				t = t[:limit]
+			}
+			v = t
+		default:
+			err = errors.New("Unsupported public method: " + method)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[pair] = v
+	}
+	return json.Marshal(result)
+}
+
+// RemoteCall answers private methods from the simulated state. Before
+// answering, waiting orders are matched against new market data.
+func (e *Exchange) RemoteCall(param map[string]string) (*btce.RemoteResult, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	info, err := e.Market.Info()
+	if err != nil {
+		return nil, err
+	}
+	if err = e.match(info); err != nil {
+		return nil, err
+	}
+	var v interface{}
+	switch param["method"] {
+	case "getInfo":
+		v = e.getInfo()
+	case "Trade":
+		v, err = e.trade(info, param)
+	case "ActiveOrders":
+		v, err = e.activeOrders(param["pair"])
+	case "OrderInfo":
+		v, err = e.orderInfo(param["order_id"])
+	case "CancelOrder":
+		v, err = e.cancelOrder(param["order_id"])
+	case "TradeHistory":
+		v, err = e.tradeHistory(param)
+	case "TransHistory":
+		v = btce.TransHistoryResult{}
+	default:
+		err = errors.New("Not supported in paper trading: " + param["method"])
+	}
+	if err != nil {
+		return &btce.RemoteResult{Error: err.Error()}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	raw := json.RawMessage(data)
+	return &btce.RemoteResult{Success: 1, Return: &raw}, nil
+}
+
+func (e *Exchange) funds() map[string]float64 {
+	funds := make(map[string]float64, len(e.State.Funds))
+	for currency, amount := range e.State.Funds {
+		funds[currency] = amount
+	}
+	return funds
+}
+
+func (e *Exchange) getInfo() btce.GetInfoResult {
+	result := btce.GetInfoResult{Funds: e.funds(),
+		ServerTime: e.Market.Now().Unix()}
+	result.Rights.Info, result.Rights.Trade = 1, 1
+	for _, o := range e.State.Orders {
+		if o.Status == 0 {
+			result.OpenOrders++
+		}
+	}
+	return result
+}
+
+func currencies(pair string) (string, string) {
+	i := strings.Index(pair, "_")
+	return pair[:i], pair[i+1:]
+}
+
+// reserve takes funds held by an order (negative amount to release).
+func (e *Exchange) reserve(o *Order, amount float64) {
+	base, quote := currencies(o.Pair)
+	if o.Type == "buy" {
+		e.State.Funds[quote] -= amount * o.Rate
+	} else {
+		e.State.Funds[base] -= amount
+	}
+}
+
+// fill executes amount of an order at a rate, crediting funds net of
+// fee and recording a trade.
+func (e *Exchange) fill(info *btce.PublicInfo, o *Order, amount float64, rate float64, maker bool) {
+	base, quote := currencies(o.Pair)
+	qfee := (100 - info.Pairs[o.Pair].Fee) / 100
+	if o.Type == "buy" {
+		e.State.Funds[base] += amount * qfee
+		// reserved at order rate, paid at fill rate
+		e.State.Funds[quote] += amount * (o.Rate - rate)
+	} else {
+		e.State.Funds[quote] += amount * rate * qfee
+	}
+	o.Amount -= amount
+	if o.Amount < epsilon {
+		o.Amount, o.Status = 0, 1
+	}
+	item := btce.TradeHistoryItem{Pair: o.Pair, Type: o.Type,
+		Amount: amount, Rate: rate, OrderId: o.Id,
+		Timestamp: e.Market.Now().Unix()}
+	if maker {
+		item.IsYourOrder = 1
+	}
+	e.State.Trades[e.State.NextTradeId] = item
+	e.State.NextTradeId++
+}
+
+// crosses checks whether an order would trade at a rate.
+func (o *Order) crosses(rate float64) bool {
+	if o.Type == "buy" {
+		return rate <= o.Rate
+	}
+	return rate >= o.Rate
+}
+
+// takeDepth fills an order against offers it crosses. New orders pay
+// offer rates (taker); waiting orders get their own rate (maker).
+func (e *Exchange) takeDepth(info *btce.PublicInfo, o *Order, depth btce.DepthInfo, maker bool) {
+	offers := depth.Bids
+	if o.Type == "buy" {
+		offers = depth.Asks
+	}
+	for _, offer := range offers {
+		if o.Status != 0 || !o.crosses(offer.Rate()) {
+			return
+		}
+		amount := offer.Amount()
+		if amount > o.Amount {
+			amount = o.Amount
+		}
+		rate := offer.Rate()
+		if maker {
+			rate = o.Rate
+		}
+		e.fill(info, o, amount, rate, maker)
+	}
+}
+
+// newTrades returns public trades of a pair not seen before. The
+// first call for a pair only remembers the newest trade.
+func (e *Exchange) newTrades(pair string) ([]btce.TradeInfo, error) {
+	trades, err := e.Market.Trades(pair)
+	if err != nil {
+		return nil, err
+	}
+	last, seen := e.State.LastTradeId[pair]
+	newest := last
+	result := []btce.TradeInfo{}
+	for _, t := range trades {
+		if seen && t.TradeId > last {
+			result = append(result, t)
+		}
+		if t.TradeId > newest {
+			newest = t.TradeId
+		}
+	}
+	e.State.LastTradeId[pair] = newest
+	sort.Slice(result, func(i, j int) bool { return result[i].TradeId < result[j].TradeId })
+	return result, nil
+}
+
+// match fills waiting orders against public trades and depth.
+func (e *Exchange) match(info *btce.PublicInfo) error {
+	byPair := map[string][]*Order{}
+	for _, o := range e.State.Orders {
+		if o.Status == 0 {
+			byPair[o.Pair] = append(byPair[o.Pair], o)
+		}
+	}
+	for pair, orders := range byPair {
+		sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+		trades, err := e.newTrades(pair)
+		if err != nil {
+			return err
+		}
+		for _, t := range trades {
+			left := t.Amount
+			for _, o := range orders {
+				if left < epsilon {
+					break
+				}
+				if o.Status != 0 || !o.crosses(t.Price) {
+					continue
+				}
+				amount := left
+				if amount > o.Amount {
+					amount = o.Amount
+				}
+				e.fill(info, o, amount, o.Rate, true)
+				left -= amount
+			}
+		}
+		depth, err := e.Market.Depth(pair)
+		if err != nil {
+			return err
+		}
+		for _, o := range orders {
+			e.takeDepth(info, o, depth, true)
+		}
+	}
+	return nil
+}
+
+func parseFloat(param map[string]string, name string) (float64, error) {
+	value, err := strconv.ParseFloat(param[name], 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid parameter: %v", name)
+	}
+	return value, nil
+}
+
+func (e *Exchange) trade(info *btce.PublicInfo, param map[string]string) (interface{}, error) {
+	pair, kind := param["pair"], param["type"]
+	pi, ok := info.Pairs[pair]
+	if !ok {
+		return nil, errors.New("Invalid pair name: " + pair)
+	}
+	if kind != "buy" && kind != "sell" {
+		return nil, errors.New("Invalid type: " + kind)
+	}
+	rate, err := parseFloat(param, "rate")
+	if err != nil {
+		return nil, err
+	}
+	amount, err := parseFloat(param, "amount")
+	if err != nil {
+		return nil, err
+	}
+	if amount < pi.MinAmount {
+		return nil, fmt.Errorf("Amount must be at least %v.", pi.MinAmount)
+	}
+	if rate < pi.MinPrice || (pi.MaxPrice > 0 && rate > pi.MaxPrice) {
+		return nil, fmt.Errorf("Price must be between %v and %v.",
+			pi.MinPrice, pi.MaxPrice)
+	}
+	o := &Order{Id: e.State.NextOrderId}
+	o.Pair, o.Type, o.Rate = pair, kind, rate
+	o.StartAmount, o.Amount = amount, amount
+	o.TimestampCreated = e.Market.Now().Unix()
+	base, quote := currencies(pair)
+	if (kind == "buy" && e.State.Funds[quote] < amount*rate-epsilon) ||
+		(kind == "sell" && e.State.Funds[base] < amount-epsilon) {
+		return nil, errors.New("It is not enough funds for creating the order.")
+	}
+	// the first call for a pair sets the starting point for trades
+	if _, err := e.newTrades(pair); err != nil {
+		return nil, err
+	}
+	depth, err := e.Market.Depth(pair)
+	if err != nil {
+		return nil, err
+	}
+	e.State.NextOrderId++
+	e.reserve(o, amount)
+	e.takeDepth(info, o, depth, false)
+	result := btce.TradeResult{Received: amount - o.Amount,
+		Remains: o.Amount}
+	if o.Status == 0 {
+		e.State.Orders[o.Id] = o
+		result.OrderId = o.Id
+	}
+	result.Funds = e.funds()
+	return result, nil
+}
+
+func (e *Exchange) activeOrders(pair string) (interface{}, error) {
+	result := btce.ActiveOrdersResult{}
+	for id, o := range e.State.Orders {
+		if o.Status == 0 && (pair == "" || pair == o.Pair) {
+			result[id] = btce.ActiveOrder{Pair: o.Pair, Type: o.Type,
+				Amount: o.Amount, Rate: o.Rate,
+				TimestampCreated: o.TimestampCreated}
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no orders")
+	}
+	return result, nil
+}
+
+func (e *Exchange) order(idString string) (*Order, error) {
+	id, err := strconv.ParseUint(idString, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid order_id")
+	}
+	o, ok := e.State.Orders[id]
+	if !ok {
+		return nil, errors.New("invalid order")
+	}
+	return o, nil
+}
+
+func (e *Exchange) orderInfo(idString string) (interface{}, error) {
+	o, err := e.order(idString)
+	if err != nil {
+		return nil, err
+	}
+	return btce.OrderInfoResult{o.Id: o.OrderInfo}, nil
+}
+
+func (e *Exchange) cancelOrder(idString string) (interface{}, error) {
+	o, err := e.order(idString)
+	if err != nil {
+		return nil, err
+	}
+	if o.Status != 0 {
+		return nil, errors.New("bad status")
+	}
+	e.reserve(o, -o.Amount)
+	o.Status = 2
+	if o.Amount < o.StartAmount {
+		o.Status = 3
+	}
+	return btce.CancelOrderResult{OrderId: o.Id, Funds: e.funds()}, nil
+}
+
+func (e *Exchange) tradeHistory(param map[string]string) (interface{}, error) {
+	uintParam := func(name string, def uint64) uint64 {
+		if value, err := strconv.ParseUint(param[name], 10, 64); err == nil {
+			return value
+		}
+		return def
+	}
+	from, count := uintParam("from", 0), uintParam("count", 1000)
+	fromId, endId := uintParam("from_id", 0), uintParam("end_id", ^uint64(0))
+	since, end := uintParam("since", 0), uintParam("end", ^uint64(0)>>1)
+	ids := []uint64{}
+	for id, t := range e.State.Trades {
+		if id >= fromId && id <= endId &&
+			uint64(t.Timestamp) >= since && uint64(t.Timestamp) <= end &&
+			(param["pair"] == "" || param["pair"] == t.Pair) {
+			ids = append(ids, id)
+		}
+	}
+	if param["order"] == "ASC" {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	} else {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	}
+	result := btce.TradeHistoryResult{}
+	for i, id := range ids {
+		if uint64(i) >= from && uint64(i) < from+count {
+			result[id] = e.State.Trades[id]
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no trades")
+	}
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package paper
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+type testMarket struct {
+	depth  btce.DepthInfo
+	trades []btce.TradeInfo
+}
+
+func (m *testMarket) Info() (*btce.PublicInfo, error) {
+	return &btce.PublicInfo{Pairs: map[string]btce.PairInfo{
+		"btc_usd": {DecimalPlaces: 3, MinAmount: 0.01, MaxPrice: 1e6, Fee: 0.2}}}, nil
+}
+func (m *testMarket) Ticker(string) (btce.TickerInfo, error)  { return btce.TickerInfo{}, nil }
+func (m *testMarket) Depth(string) (btce.DepthInfo, error)    { return m.depth, nil }
+func (m *testMarket) Trades(string) ([]btce.TradeInfo, error) { return m.trades, nil }
+func (m *testMarket) Now() time.Time                          { return time.Unix(1000, 0) }
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestPaperTrading(t *testing.T) {
+	m := &testMarket{depth: btce.DepthInfo{
+		Asks: []btce.Offer{{100, 1}}, Bids: []btce.Offer{{99, 1}}}}
+	c := &btce.Client{Backend: New(m, map[string]float64{"usd": 1000})}
+
+	r := c.Trade(btce.TradeParameters{Pair: "btc_usd", Type: "buy", Rate: 101, Amount: 0.5})
+	if r.OrderId != 0 || !near(r.Funds["btc"], 0.499) || !near(r.Funds["usd"], 950) {
+		t.Fatalf("taker fill: %+v", r)
+	}
+	r = c.Trade(btce.TradeParameters{Pair: "btc_usd", Type: "buy", Rate: 98, Amount: 1})
+	if r.OrderId == 0 || !near(r.Funds["usd"], 852) {
+		t.Fatalf("waiting order: %+v", r)
+	}
+	m.trades = []btce.TradeInfo{{Type: "bid", Price: 97, Amount: 0.3, TradeId: 5}}
+	active := c.ActiveOrders(btce.ActiveOrdersParameters{})
+	if !near(active[r.OrderId].Amount, 0.7) {
+		t.Fatalf("maker fill: %+v", active)
+	}
+	cancel := c.CancelOrder(btce.CancelOrderParameters{OrderId: r.OrderId})
+	if !near(cancel.Funds["usd"], 852+0.7*98) || !near(cancel.Funds["btc"], 0.499+0.3*0.998) {
+		t.Fatalf("cancel: %+v", cancel)
+	}
+	if info := c.OrderInfo(btce.OrderInfoParameters{OrderId: r.OrderId}); info[r.OrderId].Status != 3 {
+		t.Error("status after partial fill and cancel:", info[r.OrderId].Status)
+	}
+	if len(c.ActiveOrders(btce.ActiveOrdersParameters{})) != 0 ||
+		len(c.TradeHistory(btce.TradeHistoryParameters{})) != 2 {
+		t.Error("unexpected orders or trades left")
+	}
+}
@@ -0,0 +1,84 @@
+package btce
+
+import "fmt"
+
+// Right names, as in PermissionError
+const (
+	RightInfo     = "info"
+	RightTrade    = "trade"
+	RightWithdraw = "withdraw"
+)
+
+// Mutating maps private methods which change anything (orders,
+// funds) to the right they need.
+var Mutating = map[string]string{
+	"Trade":        RightTrade,
+	"CancelOrder":  RightTrade,
+	"WithdrawCoin": RightWithdraw,
+	"CreateCoupon": RightWithdraw,
+	"RedeemCoupon": RightInfo,
+}
+
+// PermissionError is returned by Call for a method refused locally:
+// in read-only mode, or when the key lacks a right.
+type PermissionError struct {
+	Method   string
+	Right    string
+	ReadOnly bool
+}
+
+func (e *PermissionError) Error() string {
+	if e.ReadOnly {
+		return fmt.Sprintf("Method %v is not allowed in read-only mode", e.Method)
+	}
+	return fmt.Sprintf("Method %v needs %v right, which the key lacks", e.Method, e.Right)
+}
+
+// Has tells whether a right (RightInfo, RightTrade, RightWithdraw)
+// is granted.
+func (r Rights) Has(right string) bool {
+	switch right {
+	case RightInfo:
+		return r.Info != 0
+	case RightTrade:
+		return r.Trade != 0
+	case RightWithdraw:
+		return r.Withdraw != 0
+	}
+	return false
+}
+
+// Permissions returns rights of the API key, calling getInfo only
+// the first time. Once rights are known, Call refuses methods they
+// don't allow without asking the server.
+func (c *Client) Permissions() (Rights, error) {
+	if c.rights == nil {
+		result := GetInfoResult{}
+		if err := c.Call(GetInfoParameters{}, &result); err != nil {
+			return Rights{}, err
+		}
+		c.rights = &result.Rights
+	}
+	return *c.rights, nil
+}
+
+// ForgetPermissions drops rights cached by Permissions.
+func (c *Client) ForgetPermissions() {
+	c.rights = nil
+}
+
+// checkPermission refuses a method not allowed by c.ReadOnly or by
+// cached rights.
+func (c *Client) checkPermission(method string) error {
+	right, mutating := Mutating[method]
+	if !mutating {
+		return nil
+	}
+	if c.ReadOnly {
+		return &PermissionError{Method: method, Right: right, ReadOnly: true}
+	}
+	if c.rights != nil && !c.rights.Has(right) {
+		return &PermissionError{Method: method, Right: right}
+	}
+	return nil
+}
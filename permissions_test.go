@@ -0,0 +1,46 @@
+package btce
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPermissions(t *testing.T) {
+	f := newFakeExchange()
+	defer f.Close()
+	f.Methods["getInfo"] = func(url.Values) (interface{}, error) {
+		return GetInfoResult{Rights: Rights{Info: 1, Trade: 1}}, nil
+	}
+	f.Methods["CancelOrder"] = func(url.Values) (interface{}, error) {
+		return CancelOrderResult{OrderId: 1}, nil
+	}
+	c := f.client()
+	rights, err := c.Permissions()
+	if err != nil || !rights.Has(RightTrade) || rights.Has(RightWithdraw) {
+		t.Fatal(rights, err)
+	}
+	c.Permissions()
+	if len(f.Calls) != 1 {
+		t.Error("rights not cached:", f.Calls)
+	}
+
+	err = c.Call(WithdrawCoinParameters{CoinName: "BTC", Amount: 1, Address: "x"},
+		&WithdrawCoinResult{})
+	if perr, ok := err.(*PermissionError); !ok || perr.Right != RightWithdraw || perr.ReadOnly {
+		t.Error("expected withdraw PermissionError, got", err)
+	}
+	if _, err := c.Cancel(1); err != nil {
+		t.Error(err)
+	}
+
+	c.ReadOnly = true
+	if _, err := c.Cancel(1); err == nil || !err.(*PermissionError).ReadOnly {
+		t.Error("expected read-only PermissionError, got", err)
+	}
+	if err := c.Call(GetInfoParameters{}, &GetInfoResult{}); err != nil {
+		t.Error("read-only mode blocks getInfo:", err)
+	}
+	if len(f.Calls) != 3 {
+		t.Error("refused calls reached the server:", f.Calls)
+	}
+}
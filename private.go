@@ -1,5 +1,10 @@
 package btce
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // Private API methods, results and parameters, per
 // https://wex.nz/tapi/docs
 
@@ -22,16 +27,19 @@ type GetInfoParameters struct{}
 
 type GetInfoResult struct {
 	Funds  map[string]float64
-	Rights struct {
-		Info     uint
-		Trade    uint
-		Withdraw uint
-	}
+	Rights Rights
 	TransactionCount uint  `json:"transaction_count"`
 	OpenOrders       uint  `json:"open_orders"`
 	ServerTime       int64 `json:"server_time"`
 }
 
+// Rights are permissions of an API key (1 when granted)
+type Rights struct {
+	Info     uint
+	Trade    uint
+	Withdraw uint
+}
+
 type TradeParameters struct {
 	Pair   string
 	Type   string
@@ -56,7 +64,7 @@ type OrderInfo struct {
 	StartAmount      float64 `json:"start_amount"`
 	Amount           float64
 	Rate             float64
-	TimestampCreated int64
+	TimestampCreated int64 `json:"timestamp_created"`
 	Status           uint
 }
 
@@ -104,6 +112,9 @@ type TransHistoryParameters struct {
 }
 type TransHistoryResult map[uint64]TransHistoryItem
 
+// TransHistoryItem: Type is "1" for deposits, "2" for withdrawals,
+// "4"/"5" for credits/debits. Status is 0 for cancelled or failed, 1
+// for waiting, 2 for successful and 3 for not confirmed.
 type TransHistoryItem struct {
 	Type      string
 	Amount    float64
@@ -113,6 +124,21 @@ type TransHistoryItem struct {
 	Timestamp int64
 }
 
+// UnmarshalJSON accepts type as a number, like the server sends it,
+// or as a string.
+func (t *TransHistoryItem) UnmarshalJSON(data []byte) error {
+	type item TransHistoryItem
+	v := struct {
+		*item
+		Type json.RawMessage
+	}{item: (*item)(t)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	t.Type = strings.Trim(string(v.Type), `"`)
+	return nil
+}
+
 type CoinDepositAddressParameters struct{ CoinName string }
 type CoinDepositAddressResult struct{ Address string }
 
@@ -128,6 +154,8 @@ type WithdrawCoinResult struct {
 	Funds      map[string]float64
 }
 
+// CreateCouponParameters: Receiver, when not empty, is the user name
+// of the only user allowed to redeem the coupon
 type CreateCouponParameters struct {
 	Currency string
 	Amount   float64
@@ -34,6 +34,7 @@ type TickerInfo struct {
 	CurrentVolume float64 `json:"vol_cur"`
 	Buy           float64 `json:"buy"`
 	Sell          float64 `json:"sell"`
+	Last          float64 `json:"last"`
 	Updated       int64   `json:"updated"`
 }
 
@@ -51,6 +52,17 @@ type DepthInfo struct {
 	Bids []Offer `json:"bids"`
 }
 
+// TradeInfo represents an item of the "trades" method result of
+// public API. Type is "ask" for trades executed on an ask (that is,
+// buying the base currency) and "bid" otherwise.
+type TradeInfo struct {
+	Type      string  `json:"type"`
+	Price     float64 `json:"price"`
+	Amount    float64 `json:"amount"`
+	TradeId   uint64  `json:"tid"`
+	Timestamp int64   `json:"timestamp"`
+}
+
 // GetTicker retrieves public ticker information on currency pairs
 func (c Client) GetTicker(pairs []string) (map[string]TickerInfo, error) {
 	tickers := map[string]TickerInfo{}
@@ -73,6 +85,18 @@ func (c Client) GetDepth(pairs []string, limit uint) (map[string]DepthInfo, erro
 	return depth, nil
 }
 
+// GetTrades retrieves recent public trades on currency pairs, up to
+// limit items for each pair, newest first.
+func (c Client) GetTrades(pairs []string, limit uint) (map[string][]TradeInfo, error) {
+	trades := map[string][]TradeInfo{}
+	err := c.CallPublicAPIv3("trades", pairs, &trades,
+		&url.Values{"limit": []string{fmt.Sprint(limit)}})
+	if err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
 // GetPublicInfo retrieves public API information on all available
 // currency pairs, caching it for a given client once and for all.
 func (c Client) GetPublicInfo() (*PublicInfo, error) {
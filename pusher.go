@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"github.com/toorop/go-pusher"
 	"fmt"
-	"log"
 	"sort"
 	"strconv"
 	"strings"
@@ -38,11 +37,11 @@ func convertDepth(dict map[string]string) []Offer {
 	for k, v := range dict {
 		rate, err := strconv.ParseFloat(k, 64)
 		if err != nil {
-			log.Panic(err)
+			pushFailed("Bad depth rate", err)
 		}
 		amount, err := strconv.ParseFloat(v, 64)
 		if err != nil {
-			log.Panic(err)
+			pushFailed("Bad depth amount", err)
 		}
 		result[i][0] = rate
 		result[i][1] = amount
@@ -66,12 +65,16 @@ func (w *watcher) depth(pair string) *DepthInfo {
 			Bids: map[string]string{},
 			Asks: map[string]string{}}
 		w.cache[pair] = pd
+		plog().Info("Subscribing", "channel", pair+".depth")
 		err := w.pusher.Subscribe(pair + ".depth")
 		if err != nil {
-			log.Panic(err)
+			pushFailed("Subscription failed", err, "pair", pair)
 		}
 		time.Sleep(2 * time.Second)
 		di := w.btce.Depth([]string{pair}, 100)[pair]
+		plog().Debug("Depth snapshot", "pair", pair,
+			"asks", len(di.Asks), "bids", len(di.Bids))
+		pushObserved(func(o PushObserver) { o.PushResync(pair) })
 		for _, o := range di.Asks {
 			pd.Asks[fmt.Sprint(o.Rate())] = fmt.Sprint(o.Amount())
 		}
@@ -116,8 +119,11 @@ func (w *watcher) notify(event *pusher.Event) {
 	}{}
 	err := json.Unmarshal([]byte(event.Data), &decoded)
 	if err != nil {
-		log.Panic(err)
+		pushFailed("Bad depth event", err, "channel", event.Channel)
 	}
+	plog().Debug("Depth event", "channel", event.Channel,
+		"asks", len(decoded.Ask), "bids", len(decoded.Bid))
+	pushObserved(func(o PushObserver) { o.PushEvent(event.Channel) })
 	asks, bids := w.cache[pair].Asks, w.cache[pair].Bids
 	updateCache(asks,decoded.Ask)
 	updateCache(bids,decoded.Bid)
@@ -125,17 +131,19 @@ func (w *watcher) notify(event *pusher.Event) {
 }
 
 func (w *watcher) watch(q chan request) {
+	defer pushRecover()
 	// don't do anything until a  query
 	request := <-q
 	p, err := pusher.NewClient(BTCE_APP_ID)
 	if err != nil {
-		log.Panic(err)
+		pushFailed("Pusher connection failed", err)
 	}
 	w.pchan, err = p.Bind("depth")
 	if err != nil {
-		log.Panic(err)
+		pushFailed("Pusher bind failed", err)
 	}
 	w.pusher = p
+	w.btce.Logger = plog()
 	w.info = w.btce.PublicInfo()
 	w.cache = map[string]pairData{}
 	w.convCache = map[string]*DepthInfo{}
@@ -151,6 +159,12 @@ func (w *watcher) watch(q chan request) {
 	}
 }
 
+// pushFailed logs an unrecoverable push subsystem error and panics.
+func pushFailed(msg string, err error, args ...interface{}) {
+	plog().Error(msg, append(args, "error", err)...)
+	panic(err)
+}
+
 var queue = make(chan request)
 
 func init() {
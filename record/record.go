@@ -0,0 +1,255 @@
+// Package record captures market data (public info, tickers, depth
+// and trades) into gzip-compressed JSON lines files, one per UTC
+// day, and reads it back. The format is that of backtest.Event, so
+// recordings can be replayed by backtests directly.
+package record
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+	"github.com/akovalenko/go-btce/backtest"
+)
+
+// FileSuffix ends names of recording files, which start with the
+// date (2006-01-02).
+const FileSuffix = ".jsonl.gz"
+
+// Recorder polls market data for pairs and writes it to daily files
+// in Dir. Each file starts with public info, so it can be replayed
+// on its own.
+type Recorder struct {
+	Client     *btce.Client
+	Dir        string
+	Pairs      []string
+	Interval   time.Duration // DefaultInterval if zero
+	Ticker     bool          // record tickers
+	Depth      bool          // record depth snapshots
+	Trades     bool          // record new public trades
+	UsePush    bool          // take depth from btce.FastDepth instead of polling
+	DepthLimit uint          // depth levels polled; 150 if zero
+	TradeLimit uint          // trades polled; 150 if zero
+	OnError    func(error)
+
+	day     string
+	file    *os.File
+	gz      *gzip.Writer
+	encoder *json.Encoder
+	lastTid map[string]uint64
+}
+
+func orDefault(limit uint) uint {
+	if limit == 0 {
+		return 150
+	}
+	return limit
+}
+
+// open switches to the file for the day of t, if it's not open yet.
+func (r *Recorder) open(t time.Time) error {
+	day := t.UTC().Format("2006-01-02")
+	if day == r.day {
+		return nil
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(r.Dir, day+FileSuffix),
+		os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	// each poll is written as a complete gzip member, appended to
+	// the file; readers handle members as a continuation of the
+	// stream, and a file stays readable when the recorder dies
+	// without Close
+	r.file, r.gz, r.day = file, gzip.NewWriter(file), day
+	r.encoder = json.NewEncoder(r.gz)
+	info, err := r.Client.GetPublicInfo()
+	if err != nil {
+		return err
+	}
+	return r.encoder.Encode(backtest.Event{Time: t, Info: info})
+}
+
+// endMember finishes the gzip member written so far, and starts
+// a new one.
+func (r *Recorder) endMember() error {
+	if r.gz == nil {
+		return nil
+	}
+	err := r.gz.Close()
+	r.gz.Reset(r.file)
+	return err
+}
+
+// Close closes the current file.
+func (r *Recorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file, r.gz, r.encoder, r.day = nil, nil, nil, ""
+	return err
+}
+
+// Poll records a single observation of every pair.
+func (r *Recorder) Poll() (err error) {
+	defer func() {
+		if merr := r.endMember(); err == nil {
+			err = merr
+		}
+	}()
+	now := time.Now()
+	if err := r.open(now); err != nil {
+		return err
+	}
+	if r.lastTid == nil {
+		r.lastTid = map[string]uint64{}
+	}
+	events := map[string]*backtest.Event{}
+	for _, pair := range r.Pairs {
+		events[pair] = &backtest.Event{Time: now, Pair: pair}
+	}
+	if r.Ticker {
+		tickers, err := r.Client.GetTicker(r.Pairs)
+		if err != nil {
+			return err
+		}
+		for pair, t := range tickers {
+			t := t
+			events[pair].Ticker = &t
+		}
+	}
+	if r.Depth {
+		if err := r.depth(events); err != nil {
+			return err
+		}
+	}
+	if r.Trades {
+		trades, err := r.Client.GetTrades(r.Pairs, orDefault(r.TradeLimit))
+		if err != nil {
+			return err
+		}
+		for pair, list := range trades {
+			fresh := []btce.TradeInfo{}
+			for _, t := range list {
+				if t.TradeId > r.lastTid[pair] {
+					fresh = append(fresh, t)
+				}
+			}
+			if len(fresh) > 0 {
+				r.lastTid[pair] = fresh[0].TradeId
+				events[pair].Trades = fresh
+			}
+		}
+	}
+	for _, pair := range r.Pairs {
+		e := events[pair]
+		if e.Ticker == nil && e.Depth == nil && e.Trades == nil {
+			continue
+		}
+		if err := r.encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) depth(events map[string]*backtest.Event) error {
+	if r.UsePush {
+		for _, pair := range r.Pairs {
+			events[pair].Depth = btce.FastDepth(pair)
+		}
+		return nil
+	}
+	depths, err := r.Client.GetDepth(r.Pairs, orDefault(r.DepthLimit))
+	if err != nil {
+		return err
+	}
+	for pair, d := range depths {
+		d := d
+		events[pair].Depth = &d
+	}
+	return nil
+}
+
+// DefaultInterval is used when Recorder.Interval is zero.
+const DefaultInterval = 10 * time.Second
+
+// Run polls every Interval until ctx is done. Poll errors are
+// reported to OnError (if set) and don't stop recording.
+func (r *Recorder) Run(ctx context.Context) error {
+	defer r.Close()
+	interval := r.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Poll(); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Files returns recording files in dir for days from since to until
+// (inclusive; zero times mean no limit), in chronological order.
+func Files(dir string, since time.Time, until time.Time) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+FileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, name := range names {
+		day, err := time.Parse("2006-01-02",
+			strings.TrimSuffix(filepath.Base(name), FileSuffix))
+		if err != nil {
+			continue
+		}
+		if (!since.IsZero() && day.Before(since.UTC().Truncate(24*time.Hour))) ||
+			(!until.IsZero() && day.After(until)) {
+			continue
+		}
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// DepthSnapshot is the depth of a pair at a moment.
+type DepthSnapshot struct {
+	Time time.Time
+	btce.DepthInfo
+}
+
+// Depths reads depth snapshots of a pair from recording files.
+func Depths(pair string, fileNames ...string) ([]DepthSnapshot, error) {
+	events, err := backtest.LoadFiles(fileNames...)
+	if err != nil {
+		return nil, err
+	}
+	result := []DepthSnapshot{}
+	for _, e := range events {
+		if e.Pair == pair && e.Depth != nil {
+			result = append(result, DepthSnapshot{e.Time, *e.Depth})
+		}
+	}
+	return result, nil
+}
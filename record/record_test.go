@@ -0,0 +1,117 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+	"github.com/akovalenko/go-btce/backtest"
+	"github.com/akovalenko/go-btce/paper"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	market := backtest.NewReplay(&btce.PublicInfo{})
+	client := &btce.Client{Backend: paper.New(market, nil)}
+	r := &Recorder{Client: client, Dir: dir, Pairs: []string{"btc_usd"},
+		Depth: true, Trades: true}
+	for i := 1; i <= 2; i++ {
+		market.Apply(backtest.Event{Pair: "btc_usd",
+			Depth:  &btce.DepthInfo{Asks: []btce.Offer{{float64(100 + i), 1}}},
+			Trades: []btce.TradeInfo{{TradeId: uint64(i)}}})
+		if err := r.Poll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := Files(dir, time.Now(), time.Time{})
+	if err != nil || len(files) != 1 {
+		t.Fatal(files, err)
+	}
+	snapshots, err := Depths("btc_usd", files...)
+	if err != nil || len(snapshots) != 2 || snapshots[1].Asks[0].Rate() != 102 {
+		t.Fatal(snapshots, err)
+	}
+	events, err := backtest.LoadFiles(files...)
+	if err != nil || events[0].Info == nil || len(events[2].Trades) != 1 {
+		t.Fatalf("%+v %v", events, err)
+	}
+}
+
+func TestRunDefaultInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	market := backtest.NewReplay(&btce.PublicInfo{})
+	market.Apply(backtest.Event{Pair: "btc_usd",
+		Depth: &btce.DepthInfo{Asks: []btce.Offer{{100, 1}}}})
+	r := &Recorder{Client: &btce.Client{Backend: paper.New(market, nil)},
+		Dir: dir, Pairs: []string{"btc_usd"}, Depth: true,
+		OnError: func(err error) { t.Error(err) }}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	files, err := Files(dir, time.Time{}, time.Time{})
+	if err != nil || len(files) != 1 {
+		t.Fatal(files, err)
+	}
+	snapshots, err := Depths("btc_usd", files...)
+	if err != nil || len(snapshots) != 1 {
+		t.Fatal(snapshots, err)
+	}
+}
+
+// A recorder killed without Close leaves a readable file, even with
+// a partial write at the end or another recording appended
+func TestRecordWithoutClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	market := backtest.NewReplay(&btce.PublicInfo{})
+	market.Apply(backtest.Event{Pair: "btc_usd",
+		Depth: &btce.DepthInfo{Asks: []btce.Offer{{100, 1}}}})
+	for i := 0; i < 2; i++ {
+		r := &Recorder{Client: &btce.Client{Backend: paper.New(market, nil)},
+			Dir: dir, Pairs: []string{"btc_usd"}, Depth: true}
+		if err := r.Poll(); err != nil {
+			t.Fatal(err)
+		}
+		r.file.Close()
+	}
+	files, err := Files(dir, time.Time{}, time.Time{})
+	if err != nil || len(files) != 1 {
+		t.Fatal(files, err)
+	}
+	snapshots, err := Depths("btc_usd", files...)
+	if err != nil || len(snapshots) != 2 {
+		t.Fatal(snapshots, err)
+	}
+	// a member cut short at the end
+	data, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := bytes.LastIndex(data, []byte{0x1f, 0x8b, 8})
+	if err := ioutil.WriteFile(files[0], data[:(last+len(data))/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, err = Depths("btc_usd", files...); err != nil || len(snapshots) != 1 {
+		t.Fatal(snapshots, err)
+	}
+}
@@ -7,10 +7,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -31,19 +29,6 @@ func NewClient(rawurl string) (*Client, error) {
 	return &Client{URL: rawurl}, nil
 }
 
-// ReadKey loads an API key in JSON format from a file.  The value of
-// "nonce" may be present, but it's not necessary: server's "invalid
-// nonce" message provides corrected nonce value and are used for
-// retrying private API calls immediately.
-func (c *Client) ReadKey(fileName string) error {
-	data, err := ioutil.ReadFile(fileName)
-	if err != nil {
-		return err
-	}
-	decoder := json.NewDecoder(bytes.NewReader(data))
-	return decoder.Decode(&c.Auth)
-}
-
 // ResolveReference resolves (supposedly-)relative URL according to
 // the base URL of a client.
 func (c *Client) ResolveReference(path string) string {
@@ -53,11 +38,13 @@ func (c *Client) ResolveReference(path string) string {
 	}
 	baseURL, err := url.Parse(baseStr)
 	if err != nil {
-		log.Panic(err)
+		c.logger().Error("Bad base URL", "url", baseStr, "error", err)
+		panic(err)
 	}
 	refURL, err := url.Parse(path)
 	if err != nil {
-		log.Panic(err)
+		c.logger().Error("Bad URL", "path", path, "error", err)
+		panic(err)
 	}
 	return baseURL.ResolveReference(refURL).String()
 }
@@ -67,24 +54,36 @@ func (c *Client) ResolveReference(path string) string {
 // into v on success. In addition to HTTP and decode errors,
 // server-side call failure is checked and returned in the same way.
 func (c *Client) CallPublicAPIv3(method string, pairs []string, v interface{}, values *url.Values) error {
+	inv := &Invocation{Method: method, Pairs: pairs, Values: values}
+	if err := c.invoke(inv); err != nil {
+		return err
+	}
+	errDecoder := json.NewDecoder(bytes.NewReader(inv.Response))
+	okDecoder := json.NewDecoder(bytes.NewReader(inv.Response))
+	result := &RemoteResult{Success: 1}
+	err := errDecoder.Decode(result)
+	if err == nil && result.Success == 0 {
+		return errors.New(result.Error)
+	}
+	return okDecoder.Decode(v)
+}
+
+// publicCall returns raw JSON answer of a public API method, from the
+// server or from c.Backend.
+func (c *Client) publicCall(method string, pairs []string, values *url.Values) ([]byte, error) {
+	if c.Backend != nil {
+		return c.Backend.PublicCall(method, pairs, values)
+	}
 	path := "/api/3/" + method + "/" + strings.Join(pairs, "-")
 	if values != nil {
 		url := &url.URL{Path: path, RawQuery: values.Encode()}
 		path = url.String()
 	}
 	req, err := http.NewRequest("GET", c.ResolveReference(path), nil)
-	data, err := c.doHttp(req, c.retries().GeneralError)
 	if err != nil {
-		return err
-	}
-	errDecoder := json.NewDecoder(bytes.NewReader(data))
-	okDecoder := json.NewDecoder(bytes.NewReader(data))
-	result := &RemoteResult{Success: 1}
-	err = errDecoder.Decode(result)
-	if err == nil && result.Success == 0 {
-		return errors.New(result.Error)
+		return nil, err
 	}
-	return okDecoder.Decode(v)
+	return c.doHttp(req, c.retries().GeneralError, method)
 }
 
 // SignQuery signs a query string (including nonce) with a secret
@@ -107,74 +106,91 @@ func (c *Client) makeRemoteRequest(url string, v url.Values) (*http.Request, err
 	return req, nil
 }
 
-func (c *Client) doHttp(req *http.Request, retries uint) ([]byte, error) {
-	resp, err := HttpClient.Do(req)
-	if err == nil {
-		if resp.StatusCode == 200 {
-			defer resp.Body.Close()
-			data, err := ioutil.ReadAll(resp.Body)
-			if err == nil {
-				return data, nil
+func (c *Client) doHttp(req *http.Request, retries uint, method string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		data, status, err := c.doHttpOnce(req)
+		if c.Observer != nil {
+			c.Observer.HTTPStatus(method, status)
+		}
+		if err == nil || retries == 0 {
+			return data, err
+		}
+		c.logger().Warn("HTTP request failed", "method", method,
+			"attempt", attempt, "error", err)
+		if c.Observer != nil {
+			c.Observer.Retry(method)
+		}
+		retries--
+		if req.Body != nil {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			resp.Body.Close()
 		}
 	}
-	if retries == 0 {
-		return nil, err
+}
+
+// doHttpOnce returns the body of a successful response and the HTTP
+// status (0 when there's no response at all).
+func (c *Client) doHttpOnce(req *http.Request) ([]byte, int, error) {
+	resp, err := HttpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
 	}
-	if req.Body != nil {
-		if req.GetBody == nil {
-			return nil, err
-		}
-		req.Body, err = req.GetBody()
-		if err != nil {
-			return nil, err
-		}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, resp.StatusCode, fmt.Errorf("HTTP status %v", resp.Status)
 	}
-	return c.doHttp(req, retries-1)
+	data, err := ioutil.ReadAll(resp.Body)
+	return data, resp.StatusCode, err
 }
 
 // remoteCall calls a remote method param["method"] with given
 // parameters, setting param["nonce"] from c.Auth.Nonce and
 // incrementing the latter. Error returns represent failures of HTTP
 // and decoding, but not remote-call failure, which is a normal
-// RemoteResult with Success==0.
-func (c *Client) remoteCall(param map[string]string) (*RemoteResult, error) {
+// RemoteResult with Success==0. Raw JSON answer is returned too.
+func (c *Client) remoteCall(param map[string]string, attempt int) (*RemoteResult, []byte, error) {
 	v := url.Values{}
 	for name, value := range param {
 		v.Set(name, value)
 	}
 	v.Set("nonce", fmt.Sprint(c.Auth.Nonce))
+	c.logger().Debug("RPC request", "method", param["method"],
+		"pair", param["pair"], "nonce", c.Auth.Nonce, "attempt", attempt,
+		"params", Params(param))
 	c.Auth.Nonce++
 
 	req, err := c.makeRemoteRequest(c.ResolveReference("/tapi"), v)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	data, err := c.doHttp(req, c.retries().GeneralError)
+	data, err := c.doHttp(req, c.retries().GeneralError, param["method"])
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	result := &RemoteResult{}
 	err = json.Unmarshal(data, result)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return result, nil
+	return result, data, nil
 }
 
 // remoteCallRetryNonce wraps remoteCall, ensuring c.Auth.Nonce
 // correction when needed
-func (c *Client) remoteCallRetry(param map[string]string) (*RemoteResult, error) {
+func (c *Client) remoteCallRetry(param map[string]string) ([]byte, error) {
 	retries := c.retries()
-	for {
-		result, err := c.remoteCall(param)
+	for attempt := 1; ; attempt++ {
+		result, data, err := c.remoteCall(param, attempt)
 		if err == nil {
 			if result.Success == 0 &&
 				strings.HasPrefix(result.Error, "invalid nonce parameter;") {
 				if retries.NonceCorrection == 0 {
-					return result, err
+					return data, err
 				}
 				retries.NonceCorrection--
 				newNonceString := result.Error[strings.LastIndex(result.Error, ":")+1:]
@@ -182,11 +198,13 @@ func (c *Client) remoteCallRetry(param map[string]string) (*RemoteResult, error)
 				if err != nil {
 					return nil, err
 				}
-				if traceRpc {
-					log.Println("Nonce replaced:", c.Auth.Nonce)
+				c.logger().Warn("Nonce replaced", "method", param["method"],
+					"nonce", c.Auth.Nonce, "attempt", attempt)
+				if c.Observer != nil {
+					c.Observer.NonceCorrection(param["method"])
 				}
 			} else {
-				return result, err
+				return data, err
 			}
 		} else {
 			return nil, err
@@ -279,12 +297,6 @@ func (c *Client) formatParameters(v interface{}) (map[string]string, error) {
 	return param, nil
 }
 
-var traceRpc bool
-
-func init() {
-	flag.BoolVar(&traceRpc, "traceRpc", false, "Trace BTC-e RPC calls")
-}
-
 // Call invokes a private API method, with pstruct representing
 // parameters and dst representing a return value. Type of pstruct
 // should be a struct type with name ending with "Parameters" and
@@ -304,20 +316,24 @@ func (c *Client) Call(pstruct interface{}, dst interface{}) error {
 	if err != nil {
 		return err
 	}
-	if traceRpc {
-		log.Println("RPC param:", param)
+	if err := c.checkPermission(param["method"]); err != nil {
+		return err
 	}
-	result, err := c.remoteCallRetry(param)
-	if traceRpc && result != nil {
+	inv := &Invocation{Method: param["method"], Private: true, Params: param}
+	var result *RemoteResult
+	if err = c.invoke(inv); err == nil {
+		result = &RemoteResult{}
+		err = json.Unmarshal(inv.Response, result)
+	}
+	if err == nil {
+		var ret []byte
 		if result.Return != nil {
-			log.Println("RPC result/ success:", result.Success,
-				"error:", result.Error,
-				"return(json):", string(*result.Return))
-		} else {
-			log.Println("RPC result/ success:", result.Success,
-				"error:", result.Error,
-				"no return(json)")
+			ret = *result.Return
 		}
+		c.logger().Debug("RPC result", "method", inv.Method,
+			"pair", param["pair"], "success", result.Success,
+			"error", result.Error, "return", Return(ret),
+			"latency", inv.Duration)
 	}
 
 	if err != nil {
@@ -326,6 +342,7 @@ func (c *Client) Call(pstruct interface{}, dst interface{}) error {
 	if result.Success == 0 {
 		if param["method"] == "ActiveOrders" &&
 			strings.HasPrefix(result.Error, "no orders") {
+			c.observe(pstruct, dst)
 			return nil
 		}
 		if param["method"] == "TradeHistory" && strings.HasPrefix(result.Error,
@@ -334,7 +351,20 @@ func (c *Client) Call(pstruct interface{}, dst interface{}) error {
 		}
 		return errors.New(result.Error)
 	}
-	return json.Unmarshal(*result.Return, dst)
+	err = json.Unmarshal(*result.Return, dst)
+	if err != nil {
+		return err
+	}
+	c.observe(pstruct, dst)
+	return nil
+}
+
+// observe lets client components (like Balances) learn from a
+// successful private call result.
+func (c *Client) observe(pstruct interface{}, dst interface{}) {
+	if c.Balances != nil {
+		c.Balances.observe(pstruct, dst)
+	}
 }
 
 func (c *Client) retries() Retries {
@@ -0,0 +1,371 @@
+// Package trigger emulates stop-loss, take-profit, trailing stop and
+// OCO (one-cancels-other) orders on the client side: an Engine
+// watches prices and places an order when a trigger condition hits.
+//
+// Armed triggers are kept in a JSON file, so a restarted process
+// continues watching them, including the best price seen by trailing
+// stops.
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akovalenko/go-btce"
+)
+
+// Source is the price a trigger watches.
+type Source string
+
+const (
+	Last Source = "last" // last trade rate (ticker)
+	Bid  Source = "bid"  // best bid: what we'd get selling
+	Ask  Source = "ask"  // best ask: what we'd pay buying
+)
+
+// Condition is a kind of trigger.
+type Condition string
+
+const (
+	Above    Condition = "above"    // fires when price >= Price
+	Below    Condition = "below"    // fires when price <= Price
+	Trailing Condition = "trailing" // fires after a reversal by Percent
+)
+
+// Prices are current prices of a pair.
+type Prices struct {
+	Last float64
+	Bid  float64
+	Ask  float64
+}
+
+func (p Prices) get(source Source) float64 {
+	switch source {
+	case Bid:
+		return p.Bid
+	case Ask:
+		return p.Ask
+	}
+	return p.Last
+}
+
+// Trigger is an order waiting for a price condition.
+//
+// A trailing stop for a sell order follows the highest price seen
+// and fires when the price falls Percent below it; for a buy order it
+// follows the lowest price and fires when the price rises Percent
+// above it.
+//
+// Order.Rate of zero means a market order (see
+// btce.Client.MarketSell) limited by MaxSlippage. Triggers sharing a
+// non-empty Group are OCO: when one fires, the others are removed.
+type Trigger struct {
+	Id          string
+	Pair        string
+	Source      Source
+	Condition   Condition
+	Price       float64
+	Percent     float64
+	Extreme     float64 // best price seen by a trailing stop
+	Order       btce.TradeParameters
+	MaxSlippage float64
+	Group       string
+}
+
+// observe updates trailing state with a price, reporting whether it
+// changed.
+func (t *Trigger) observe(price float64) bool {
+	if t.Condition != Trailing || price == 0 {
+		return false
+	}
+	if t.Extreme == 0 ||
+		(t.Order.Type == "sell" && price > t.Extreme) ||
+		(t.Order.Type == "buy" && price < t.Extreme) {
+		t.Extreme = price
+		return true
+	}
+	return false
+}
+
+// hit checks whether the trigger condition is met by a price.
+func (t *Trigger) hit(price float64) bool {
+	if price == 0 {
+		return false
+	}
+	switch t.Condition {
+	case Above:
+		return price >= t.Price
+	case Below:
+		return price <= t.Price
+	case Trailing:
+		if t.Order.Type == "sell" {
+			return price <= t.Extreme*(1-t.Percent/100)
+		}
+		return price >= t.Extreme*(1+t.Percent/100)
+	}
+	return false
+}
+
+func (t *Trigger) validate() error {
+	switch {
+	case t.Pair == "" || t.Pair != t.Order.Pair:
+		return errors.New("Trigger pair must match its order pair")
+	case t.Order.Type != "buy" && t.Order.Type != "sell":
+		return errors.New("Order type: buy or sell expected, got " + t.Order.Type)
+	case t.Condition == Trailing && t.Percent <= 0:
+		return errors.New("Trailing stop needs a positive percent")
+	case t.Condition != Above && t.Condition != Below && t.Condition != Trailing:
+		return errors.New("Unknown trigger condition: " + string(t.Condition))
+	}
+	return nil
+}
+
+// Fired reports a trigger that hit. When placing the order failed,
+// Err is set and the trigger stays armed, to be retried on the next
+// check.
+type Fired struct {
+	Trigger Trigger
+	Price   float64
+	Result  *btce.TradeResult
+	Err     error
+}
+
+// Engine watches armed triggers.
+type Engine struct {
+	Client   *btce.Client
+	File     string        // where triggers are kept; none if empty
+	Interval time.Duration // polling interval
+	UsePush  bool          // take bid and ask from btce.FastDepth
+	OnFire   func(Fired)
+	mutex    sync.Mutex
+	triggers map[string]*Trigger
+	nextId   int
+}
+
+// DefaultInterval is used when Engine.Interval is zero.
+const DefaultInterval = 2 * time.Second
+
+// Load creates an Engine, reading triggers armed earlier from file
+// (which may not exist yet).
+func Load(c *btce.Client, file string) (*Engine, error) {
+	e := &Engine{Client: c, File: file, triggers: map[string]*Trigger{}}
+	if file == "" {
+		return e, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &e.triggers); err != nil {
+		return nil, err
+	}
+	e.nextId = len(e.triggers)
+	return e, nil
+}
+
+// save writes triggers to the file; called with the mutex held.
+func (e *Engine) save() error {
+	if e.File == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(e.triggers, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempFile := e.File + ".tmpnew"
+	if err = ioutil.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, e.File)
+}
+
+// Add arms a trigger, assigning an Id if it has none, and returns the
+// Id.
+func (e *Engine) Add(t Trigger) (string, error) {
+	if err := t.validate(); err != nil {
+		return "", err
+	}
+	if t.Source == "" {
+		t.Source = Last
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for t.Id == "" || e.triggers[t.Id] != nil {
+		e.nextId++
+		t.Id = fmt.Sprint(e.nextId)
+	}
+	e.triggers[t.Id] = &t
+	return t.Id, e.save()
+}
+
+// Remove disarms a trigger.
+func (e *Engine) Remove(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.triggers[id] == nil {
+		return errors.New("Unknown trigger: " + id)
+	}
+	delete(e.triggers, id)
+	return e.save()
+}
+
+// Triggers returns armed triggers, ordered by Id.
+func (e *Engine) Triggers() []Trigger {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	result := make([]Trigger, 0, len(e.triggers))
+	for _, t := range e.triggers {
+		result = append(result, *t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
+
+// place places the order of a trigger.
+func (e *Engine) place(t Trigger) (*btce.TradeResult, error) {
+	if t.Order.Rate != 0 {
+		result := &btce.TradeResult{}
+		return result, e.Client.Call(t.Order, result)
+	}
+	var r *btce.MarketResult
+	var err error
+	if t.Order.Type == "buy" {
+		r, err = e.Client.MarketBuy(t.Pair, t.Order.Amount, t.MaxSlippage)
+	} else {
+		r, err = e.Client.MarketSell(t.Pair, t.Order.Amount, t.MaxSlippage)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r.Trade, nil
+}
+
+// Check evaluates triggers against prices (keyed by pair), placing
+// orders for those that hit, and returns them.
+func (e *Engine) Check(prices map[string]Prices) ([]Fired, error) {
+	e.mutex.Lock()
+	changed := false
+	hits := []Fired{}
+	ids := make([]string, 0, len(e.triggers))
+	for id := range e.triggers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		t := e.triggers[id]
+		p, ok := prices[t.Pair]
+		if !ok {
+			continue
+		}
+		price := p.get(t.Source)
+		if t.observe(price) {
+			changed = true
+		} else if t.hit(price) {
+			hits = append(hits, Fired{Trigger: *t, Price: price})
+		}
+	}
+	e.mutex.Unlock()
+
+	fired := []Fired{}
+	done := map[string]bool{}
+	for _, hit := range hits {
+		group := hit.Trigger.Group
+		if group != "" && done[group] {
+			continue
+		}
+		hit.Result, hit.Err = e.place(hit.Trigger)
+		if hit.Err == nil {
+			e.mutex.Lock()
+			delete(e.triggers, hit.Trigger.Id)
+			if group != "" {
+				done[group] = true
+				for id, t := range e.triggers {
+					if t.Group == group {
+						delete(e.triggers, id)
+					}
+				}
+			}
+			e.mutex.Unlock()
+			changed = true
+		}
+		fired = append(fired, hit)
+		if e.OnFire != nil {
+			e.OnFire(hit)
+		}
+	}
+	var err error
+	if changed {
+		e.mutex.Lock()
+		err = e.save()
+		e.mutex.Unlock()
+	}
+	return fired, err
+}
+
+// prices fetches current prices for pairs with armed triggers.
+func (e *Engine) prices() (map[string]Prices, error) {
+	seen := map[string]bool{}
+	pairs := []string{}
+	for _, t := range e.Triggers() {
+		if !seen[t.Pair] {
+			seen[t.Pair] = true
+			pairs = append(pairs, t.Pair)
+		}
+	}
+	prices := map[string]Prices{}
+	if len(pairs) == 0 {
+		return prices, nil
+	}
+	sort.Strings(pairs)
+	tickers, err := e.Client.GetTicker(pairs)
+	if err != nil {
+		return nil, err
+	}
+	for pair, t := range tickers {
+		// ticker's "buy" is the rate to buy at, that is the best ask
+		p := Prices{Last: t.Last, Bid: t.Sell, Ask: t.Buy}
+		if e.UsePush {
+			if d := btce.FastDepth(pair); d != nil &&
+				len(d.Bids) > 0 && len(d.Asks) > 0 {
+				p.Bid, p.Ask = d.Bids[0].Rate(), d.Asks[0].Rate()
+			}
+		}
+		prices[pair] = p
+	}
+	return prices, nil
+}
+
+// Run checks triggers every Interval until ctx is done. Errors of
+// price retrieval and order placement are reported to OnFire or
+// skipped until the next check; only errors saving the trigger file
+// stop the engine.
+func (e *Engine) Run(ctx context.Context) error {
+	interval := e.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if prices, err := e.prices(); err == nil {
+			if _, err := e.Check(prices); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package trigger
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akovalenko/go-btce"
+)
+
+func TestTrailingStop(t *testing.T) {
+	stop := &Trigger{Condition: Trailing, Percent: 10,
+		Order: btce.TradeParameters{Type: "sell"}}
+	for _, price := range []float64{100, 120, 110} {
+		stop.observe(price)
+		if stop.hit(price) {
+			t.Fatal("fired too early at", price)
+		}
+	}
+	if stop.Extreme != 120 {
+		t.Error("extreme not followed:", stop.Extreme)
+	}
+	if stop.observe(108) || !stop.hit(108) {
+		t.Error("not fired at 108")
+	}
+}
+
+func TestOCO(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/3/info") {
+			fmt.Fprint(w, `{"pairs":{"btc_usd":{"decimal_places":3}}}`)
+			return
+		}
+		fmt.Fprint(w, `{"success":1,"return":{"order_id":1,"remains":1}}`)
+	}))
+	defer server.Close()
+	e, err := Load(&btce.Client{URL: server.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := btce.TradeParameters{Pair: "btc_usd", Type: "sell", Amount: 1, Rate: 1}
+	for _, tr := range []Trigger{
+		{Pair: "btc_usd", Condition: Below, Price: 90, Order: order, Group: "g"},
+		{Pair: "btc_usd", Condition: Above, Price: 110, Order: order, Group: "g"},
+		{Pair: "btc_usd", Condition: Above, Price: 200, Order: order},
+	} {
+		if _, err := e.Add(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fired, err := e.Check(map[string]Prices{"btc_usd": {Last: 100}})
+	if err != nil || len(fired) != 0 {
+		t.Fatal("unexpected fire:", fired, err)
+	}
+	fired, err = e.Check(map[string]Prices{"btc_usd": {Last: 120}})
+	if err != nil || len(fired) != 1 || fired[0].Err != nil {
+		t.Fatal("fired:", fired, err)
+	}
+	if left := e.Triggers(); len(left) != 1 || left[0].Group != "" {
+		t.Error("OCO group not removed:", left)
+	}
+}
+
+func TestPricesDistinctPairs(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, `{"btc_usd":{"last":100},"ltc_usd":{"last":5}}`)
+	}))
+	defer server.Close()
+	e, err := Load(&btce.Client{URL: server.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pair := range []string{"ltc_usd", "btc_usd", "btc_usd"} {
+		if _, err := e.Add(Trigger{Pair: pair, Condition: Above, Price: 1000,
+			Order: btce.TradeParameters{Pair: pair, Type: "buy", Amount: 1, Rate: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prices, err := e.prices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/api/3/ticker/btc_usd-ltc_usd" || len(prices) != 2 {
+		t.Error(path, prices)
+	}
+}
@@ -2,6 +2,8 @@ package btce
 
 import (
 	"encoding/json"
+	"log/slog"
+	"net/url"
 )
 
 
@@ -56,6 +58,51 @@ type Client struct {
 	Info    *PublicInfo // queried and stored when first needed
 	Auth    Auth
 	Retries *Retries
+
+	// Balances, when not nil, is kept up to date by private calls
+	Balances *Balances
+
+	// Backend, when not nil, answers API calls instead of the
+	// server (see package paper for a paper-trading backend)
+	Backend Backend
+
+	// Logger, when not nil, gets debug records of every call and
+	// warnings on retries (secrets are redacted)
+	Logger *slog.Logger
+
+	// Observer, when not nil, is told about HTTP-level events
+	// that middleware can't see (see package metrics)
+	Observer CallObserver
+
+	// ReadOnly, when true, makes Call refuse methods changing
+	// anything (see Mutating), whatever the key allows
+	ReadOnly bool
+
+	middleware []Middleware // see Use
+	rights     *Rights      // cached by Permissions
+}
+
+// CallObserver is notified of HTTP responses and retries made while
+// performing an API call.
+type CallObserver interface {
+	// HTTPStatus is called for each HTTP request, with status 0
+	// when no response was received
+	HTTPStatus(method string, status int)
+	// Retry is called before repeating a failed HTTP request
+	Retry(method string)
+	// NonceCorrection is called when a private call is repeated
+	// with a nonce corrected by the server
+	NonceCorrection(method string)
+}
+
+// Backend answers public and private API calls in place of the
+// server, receiving the same parameters and returning the same
+// results: the raw JSON answer of a public method, and RemoteResult
+// of a private method whose parameters (including "method" but not
+// "nonce") are in param.
+type Backend interface {
+	PublicCall(method string, pairs []string, values *url.Values) ([]byte, error)
+	RemoteCall(param map[string]string) (*RemoteResult, error)
 }
 
 // RemoteResult represents a result of a private API call (always
@@ -0,0 +1,278 @@
+package btce
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Withdrawal is a WithdrawCoin or CreateCoupon call, as checked by
+// WithdrawalPolicy.
+type Withdrawal struct {
+	Method   string  `json:"method"`
+	Currency string  `json:"currency"` // lowercase
+	Amount   float64 `json:"amount"`
+	Address  string  `json:"address"` // coupon receiver for CreateCoupon
+}
+
+// WithdrawalLimit limits amounts of a currency, when non-zero.
+type WithdrawalLimit struct {
+	PerTransaction float64 `json:"per_transaction"`
+	Daily          float64 `json:"daily"` // rolling 24 hours
+}
+
+// Kinds of WithdrawalError
+const (
+	NotWhitelisted       = "not whitelisted"
+	OverTransactionLimit = "over per-transaction limit"
+	OverDailyLimit       = "over daily limit"
+	NotConfirmed         = "not confirmed"
+)
+
+// WithdrawalError is returned for a withdrawal refused by policy.
+type WithdrawalError struct {
+	Kind string
+	Withdrawal
+	Limit float64 // for limit kinds
+	Used  float64 // for OverDailyLimit: withdrawn in last 24 hours
+}
+
+func (e *WithdrawalError) Error() string {
+	switch e.Kind {
+	case OverTransactionLimit:
+		return fmt.Sprintf("Withdrawal of %v %v is over the limit of %v",
+			e.Amount, e.Currency, e.Limit)
+	case OverDailyLimit:
+		return fmt.Sprintf("Withdrawal of %v %v is over the daily limit of %v (%v used)",
+			e.Amount, e.Currency, e.Limit, e.Used)
+	case NotWhitelisted:
+		return fmt.Sprintf("Address %q is not whitelisted for %v", e.Address, e.Currency)
+	}
+	return fmt.Sprintf("Withdrawal of %v %v is %v", e.Amount, e.Currency, e.Kind)
+}
+
+// AuditEntry is a line of the withdrawal audit log.
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	Withdrawal
+	Result string `json:"result"` // "ok", "refused", "failed" or "unknown"
+	Error  string `json:"error,omitempty"`
+}
+
+// WithdrawalPolicy guards WithdrawCoin and CreateCoupon calls of
+// clients it's attached to. Each attempt is checked against the
+// whitelist and limits, then confirmed by Confirm, and written to
+// the audit log whatever the outcome. An attempt without an answer
+// from the server (a transport error or a timeout) may have been
+// done, so its outcome is "unknown" and it counts towards daily
+// limits like a successful one.
+type WithdrawalPolicy struct {
+	// Whitelist lists allowed addresses by currency (lowercase).
+	// Currencies without an entry can't be withdrawn at all;
+	// "*" allows any address. A coupon receiver is its address
+	// ("" for a coupon anyone can redeem).
+	Whitelist map[string][]string `json:"whitelist"`
+	// Limits by currency (lowercase)
+	Limits map[string]WithdrawalLimit `json:"limits"`
+	// Confirm is called for each withdrawal passing the checks; it
+	// must return true for the withdrawal to proceed, and there's
+	// no withdrawal at all when it's nil.
+	Confirm func(Withdrawal) bool `json:"-"`
+	// AuditLog is a JSON lines file of AuditEntry, also used to
+	// know amounts withdrawn in the last 24 hours
+	AuditLog string           `json:"audit_log"`
+	Now      func() time.Time `json:"-"` // time.Now if nil
+
+	mu     sync.Mutex
+	recent []AuditEntry // successful or unknown, for daily limits
+	loaded bool
+}
+
+// Attach makes a client apply the policy to its calls.
+func (p *WithdrawalPolicy) Attach(c *Client) {
+	c.Use(p.Middleware)
+}
+
+// Middleware applies the policy to WithdrawCoin and CreateCoupon
+// invocations.
+func (p *WithdrawalPolicy) Middleware(next Invoker) Invoker {
+	return func(inv *Invocation) error {
+		w, ok := withdrawalOf(inv)
+		if !ok {
+			return next(inv)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err := p.check(w); err != nil {
+			return p.audit(w, "refused", err, err)
+		}
+		if p.Confirm == nil || !p.Confirm(w) {
+			err := &WithdrawalError{Kind: NotConfirmed, Withdrawal: w}
+			return p.audit(w, "refused", err, err)
+		}
+		err := next(inv)
+		if err == nil {
+			result := RemoteResult{}
+			if json.Unmarshal(inv.Response, &result) == nil && result.Success == 0 {
+				return p.audit(w, "failed", fmt.Errorf("%v", result.Error), nil)
+			}
+			return p.audit(w, "ok", nil, nil)
+		}
+		return p.audit(w, "unknown", err, err)
+	}
+}
+
+// Check tells whether a withdrawal is allowed by the whitelist and
+// limits, without confirming or doing it.
+func (p *WithdrawalPolicy) Check(w Withdrawal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.check(w)
+}
+
+// Withdrawn returns the amount of a currency withdrawn in the last
+// 24 hours, successfully or with an unknown outcome.
+func (p *WithdrawalPolicy) Withdrawn(currency string) (float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.load(); err != nil {
+		return 0, err
+	}
+	return p.withdrawn(strings.ToLower(currency)), nil
+}
+
+func (p *WithdrawalPolicy) check(w Withdrawal) error {
+	if err := p.load(); err != nil {
+		return err
+	}
+	allowed := false
+	for _, address := range p.Whitelist[w.Currency] {
+		if address == "*" || address == w.Address {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return &WithdrawalError{Kind: NotWhitelisted, Withdrawal: w}
+	}
+	limit := p.Limits[w.Currency]
+	if limit.PerTransaction > 0 && w.Amount > limit.PerTransaction {
+		return &WithdrawalError{Kind: OverTransactionLimit, Withdrawal: w,
+			Limit: limit.PerTransaction}
+	}
+	if limit.Daily > 0 {
+		if used := p.withdrawn(w.Currency); used+w.Amount > limit.Daily {
+			return &WithdrawalError{Kind: OverDailyLimit, Withdrawal: w,
+				Limit: limit.Daily, Used: used}
+		}
+	}
+	return nil
+}
+
+func (p *WithdrawalPolicy) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
+}
+
+func (p *WithdrawalPolicy) withdrawn(currency string) float64 {
+	since := p.now().Add(-24 * time.Hour)
+	sum := 0.0
+	for _, e := range p.recent {
+		if e.Currency == currency && e.Time.After(since) {
+			sum += e.Amount
+		}
+	}
+	return sum
+}
+
+// load reads withdrawals of the last 24 hours that count towards
+// limits from the audit log, once.
+func (p *WithdrawalPolicy) load() error {
+	if p.loaded || p.AuditLog == "" {
+		return nil
+	}
+	file, err := os.Open(p.AuditLog)
+	if os.IsNotExist(err) {
+		p.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	since := p.now().Add(-24 * time.Hour)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		e := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("%v: %v", p.AuditLog, err)
+		}
+		if counted(e.Result) && e.Time.After(since) {
+			p.recent = append(p.recent, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	p.loaded = true
+	return nil
+}
+
+// audit records an attempt, returning ret (or an audit log failure,
+// which is worse).
+func (p *WithdrawalPolicy) audit(w Withdrawal, result string, cause error, ret error) error {
+	e := AuditEntry{Time: p.now(), Withdrawal: w, Result: result}
+	if cause != nil {
+		e.Error = cause.Error()
+	}
+	if counted(result) {
+		p.recent = append(p.recent, e)
+	}
+	if p.AuditLog == "" {
+		return ret
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Audit log failed (%v) after withdrawal %v: %v", err, result, ret)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Audit log failed (%v) after withdrawal %v: %v", err, result, ret)
+	}
+	return ret
+}
+
+// counted tells whether withdrawals with a result count towards
+// daily limits.
+func counted(result string) bool {
+	return result == "ok" || result == "unknown"
+}
+
+// withdrawalOf describes a WithdrawCoin or CreateCoupon invocation.
+func withdrawalOf(inv *Invocation) (Withdrawal, bool) {
+	if !inv.Private {
+		return Withdrawal{}, false
+	}
+	w := Withdrawal{Method: inv.Method}
+	switch inv.Method {
+	case "WithdrawCoin":
+		w.Currency, w.Address = inv.Params["coinName"], inv.Params["address"]
+	case "CreateCoupon":
+		w.Currency, w.Address = inv.Params["currency"], inv.Params["receiver"]
+	default:
+		return Withdrawal{}, false
+	}
+	w.Currency = strings.ToLower(w.Currency)
+	w.Amount, _ = strconv.ParseFloat(inv.Params["amount"], 64)
+	return w, true
+}
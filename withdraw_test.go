@@ -0,0 +1,136 @@
+package btce
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithdrawalPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "withdraw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeExchange()
+	defer f.Close()
+	f.Methods["WithdrawCoin"] = func(v url.Values) (interface{}, error) {
+		return WithdrawCoinResult{TransId: 1}, nil
+	}
+	now := time.Unix(100000, 0)
+	confirmed := []Withdrawal{}
+	p := &WithdrawalPolicy{
+		Whitelist: map[string][]string{"btc": {"good"}},
+		Limits:    map[string]WithdrawalLimit{"btc": {PerTransaction: 1, Daily: 1.5}},
+		Confirm: func(w Withdrawal) bool {
+			confirmed = append(confirmed, w)
+			return w.Amount != 0.25
+		},
+		AuditLog: filepath.Join(dir, "audit.jsonl"),
+		Now:      func() time.Time { return now },
+	}
+	c := f.client()
+	p.Attach(c)
+	withdraw := func(address string, amount float64) error {
+		return c.Call(WithdrawCoinParameters{CoinName: "BTC", Amount: amount,
+			Address: address}, &WithdrawCoinResult{})
+	}
+	kind := func(err error) string {
+		if werr, ok := err.(*WithdrawalError); ok {
+			return werr.Kind
+		}
+		return ""
+	}
+	if err := withdraw("evil", 0.1); kind(err) != NotWhitelisted {
+		t.Error("expected NotWhitelisted, got", err)
+	}
+	if err := withdraw("good", 1.1); kind(err) != OverTransactionLimit {
+		t.Error("expected OverTransactionLimit, got", err)
+	}
+	if err := withdraw("good", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := withdraw("good", 0.25); kind(err) != NotConfirmed {
+		t.Error("expected NotConfirmed, got", err)
+	}
+	if err := withdraw("good", 0.75); kind(err) != OverDailyLimit {
+		t.Error("expected OverDailyLimit, got", err)
+	}
+	if len(f.Calls) != 1 || len(confirmed) != 2 {
+		t.Error("unexpected calls or confirmations:", f.Calls, confirmed)
+	}
+
+	// a new policy learns recent withdrawals from the audit log
+	now = now.Add(23 * time.Hour)
+	p2 := &WithdrawalPolicy{AuditLog: p.AuditLog, Now: p.Now}
+	if used, err := p2.Withdrawn("BTC"); err != nil || used != 1 {
+		t.Error("withdrawn in 24h:", used, err)
+	}
+	now = now.Add(2 * time.Hour)
+	p3 := &WithdrawalPolicy{AuditLog: p.AuditLog, Now: p.Now}
+	if used, _ := p3.Withdrawn("btc"); used != 0 {
+		t.Error("withdrawn in 24h after a day:", used)
+	}
+
+	file, err := os.Open(p.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	results := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		e := AuditEntry{}
+		json.Unmarshal(scanner.Bytes(), &e)
+		results = append(results, e.Result)
+	}
+	if len(results) != 5 || results[2] != "ok" || results[4] != "refused" {
+		t.Error("audit log results:", results)
+	}
+}
+
+// Withdrawals without an answer may have happened, and count
+func TestWithdrawalUnknown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "withdraw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// the connection is dropped after the request is sent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tapi" {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, `{"pairs":{}}`)
+	}))
+	defer server.Close()
+	c := &Client{URL: server.URL, Retries: &Retries{}}
+	p := &WithdrawalPolicy{
+		Whitelist: map[string][]string{"btc": {"*"}},
+		Limits:    map[string]WithdrawalLimit{"btc": {Daily: 1.5}},
+		Confirm:   func(Withdrawal) bool { return true },
+		AuditLog:  filepath.Join(dir, "audit.jsonl"),
+	}
+	p.Attach(c)
+	if err := c.Call(WithdrawCoinParameters{CoinName: "BTC", Amount: 1,
+		Address: "a"}, &WithdrawCoinResult{}); err == nil {
+		t.Fatal("expected a transport error")
+	}
+	if err := p.Check(Withdrawal{Currency: "btc", Amount: 1, Address: "a"}); err == nil {
+		t.Error("unknown withdrawal not counted")
+	}
+	p2 := &WithdrawalPolicy{AuditLog: p.AuditLog}
+	if used, err := p2.Withdrawn("btc"); err != nil || used != 1 {
+		t.Error("unknown withdrawal not counted from the log:", used, err)
+	}
+}